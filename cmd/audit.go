@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/spf13/cobra"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/audit"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/get"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/git"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/render"
+)
+
+// Passed via flags on the audit command only
+var outputFormat string
+
+// auditCmd represents the audit command. It runs a check on a collection of repositories
+// and reports what it found, without changing anything.
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Runs a check command on a collection of repositories and reports the results.",
+	Long: `Given a GitHub organisation and a blob of repository names, clone each
+repository, run the check command on its default branch and report its
+status and output per repository as a table or JSON. A check exiting 0 passes,
+anything else fails. No branches, commits or pull requests are ever created,
+and the clones are removed afterwards.
+
+An example of this would be:
+
+cloud-platform-git-xargs audit --command "grep -rh required_version --include '*.tf' ." \
+							   --organisation "ministryofjustice" \
+							   --repository "cloud-platform-terraform"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// You must set a GITHUB_OAUTH_TOKEN environment variable
+		token := os.Getenv("GITHUB_OAUTH_TOKEN")
+		if token == "" {
+			return errors.New("you must have the GITHUB_OAUTH_TOKEN env var")
+		}
+
+		if command == "" {
+			return errors.New("a check command is needed")
+		}
+		if outputFormat != "table" && outputFormat != "json" {
+			return fmt.Errorf("unknown output format %q, want table or json", outputFormat)
+		}
+
+		err := execute.ValidateEnv(envPairs)
+		if err != nil {
+			return err
+		}
+
+		client := GitHubClient(token)
+
+		fmt.Fprintln(os.Stderr, "Fetching repositories...")
+
+		repos, err := get.FetchRepositories(client, org, repos, file)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, "Repositories fetched.")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		results := &audit.Report{}
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
+
+			result := &audit.Repo{Name: repo.GetName()}
+			results.Repos = append(results.Repos, result)
+
+			err := auditRepo(ctx, repo, client, result)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", repo.GetName(), err)
+			}
+		}
+
+		if outputFormat == "json" {
+			err = results.WriteJSON(os.Stdout)
+		} else {
+			err = results.Print(os.Stdout)
+		}
+		if err != nil {
+			return err
+		}
+
+		if reportFile != "" {
+			f, err := os.Create(reportFile)
+			if err != nil {
+				return fmt.Errorf("error writing report: %w", err)
+			}
+			defer f.Close()
+
+			err = results.WriteJSON(f)
+			if err != nil {
+				return fmt.Errorf("error writing report: %w", err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return errors.New("audit interrupted")
+		}
+		if failed := results.Count(audit.Error); failed > 0 {
+			return fmt.Errorf("%d of %d repositories couldn't be audited", failed, len(repos))
+		}
+		return nil
+	},
+}
+
+// auditRepo clones a repository, runs the check on its default branch and records the
+// outcome in result. The clone is always removed.
+func auditRepo(ctx context.Context, repo *github.Repository, client *github.Client, result *audit.Repo) error {
+	result.Status = audit.Error
+
+	repoDir, localRepo, err := git.Clone(ctx, repo, client, git.CloneOptions{Depth: 1, SingleBranch: true})
+	if repoDir != "" {
+		defer os.RemoveAll(repoDir)
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("error cloning repository: %s", err)
+		return errors.New(result.Error)
+	}
+
+	tree, err := localRepo.Worktree()
+	if err != nil {
+		result.Error = fmt.Sprintf("error getting worktree: %s", err)
+		return errors.New(result.Error)
+	}
+
+	// An audit is always a dry run, as far as the check is concerned
+	env, err := execute.RepoEnv(repo, repoDir, true)
+	if err != nil {
+		result.Error = fmt.Sprintf("error building environment: %s", err)
+		return errors.New(result.Error)
+	}
+
+	check := command
+	if templateCommand {
+		check, err = render.Render("command", command, render.RepoData{Repo: repo})
+		if err != nil {
+			result.Error = fmt.Sprintf("error rendering command: %s", err)
+			return errors.New(result.Error)
+		}
+	}
+
+	result.Output, err = execute.Command(ctx, repoDir, check, tree, execute.Options{
+		Loop:    loop,
+		Filter:  dirFilter,
+		Timeout: timeout,
+		Env:     append(env, envPairs...),
+		// Carry on so every directory's result is reported
+		ContinueOnError: true,
+	})
+	if err == nil {
+		result.Status = audit.Pass
+		return nil
+	}
+
+	// The check failing is a finding, not an error, unless it didn't get to run
+	ran := len(result.Output) > 0 && ctx.Err() == nil
+	for _, out := range result.Output {
+		ran = ran && out.ExitCode >= 0
+	}
+	if !ran {
+		result.Error = err.Error()
+		return err
+	}
+	result.Status = audit.Fail
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVarP(&command, "command", "c", "", "the check to run i.e. grep -r required_version. Exiting 0 passes, anything else fails.")
+	auditCmd.Flags().BoolVar(&templateCommand, "template-command", false, "render the check as a Go template per repository i.e. test -f {{.Repo.Name}}.tf")
+	auditCmd.Flags().StringVarP(&repos, "repository", "r", "", "a blob of the repository name i.e. cloud-platform-terraform")
+	auditCmd.Flags().StringVarP(&org, "organisation", "o", "ministryofjustice", "organisation of the repository i.e. ministryofjustice")
+	auditCmd.Flags().StringVarP(&file, "file", "f", "", "path to file containing list of repositories to process.")
+	auditCmd.Flags().BoolVarP(&loop, "loop-dir", "l", false, "run the check in every directory of the repository.")
+	auditCmd.Flags().StringSliceVar(&dirFilter.Include, "dir-include", nil, "with loop-dir, only check directories whose path or name matches these globs i.e. namespaces/*")
+	auditCmd.Flags().StringSliceVar(&dirFilter.Exclude, "dir-exclude", nil, "with loop-dir, skip directories, and everything below them, whose path or name matches these globs i.e. .terraform")
+	auditCmd.Flags().StringSliceVar(&dirFilter.Contains, "dir-contains", nil, "with loop-dir, only check directories containing a file matching these globs i.e. *.tf")
+	auditCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long each check may take before it's killed i.e. 1m. No limit by default.")
+	auditCmd.Flags().StringArrayVarP(&envPairs, "env", "e", nil, "KEY=VALUE environment variables to set for the check. Can be repeated.")
+	auditCmd.Flags().StringVar(&outputFormat, "output", "table", "how to print the results, table or json.")
+	auditCmd.Flags().StringVar(&reportFile, "report", "", "path to also write a JSON report of the audit to.")
+}
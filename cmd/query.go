@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/spf13/cobra"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/get"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/query"
+)
+
+// Passed via flags on the query command only
+var (
+	extractSpec string
+	queryPaths  []string
+)
+
+// queryCmd represents the query command. It reads values out of files across a collection
+// of repositories and tabulates them.
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Extracts values from files across a collection of repositories.",
+	Long: `Given a GitHub organisation and a blob of repository names, read the files
+matching --path from each repository's default branch through the GitHub
+contents API, extract values from them and print a pivot table of how many
+times each repository has each value, or JSON with --output json.
+
+Extractors are given as kind:expression:
+
+  hcl:terraform.required_version       an HCL attribute path, as for hcl-set
+  yaml:jobs.*.steps[0].uses            a YAML path, as for yaml-set
+  json:$.engines.node                  a JSON path
+  regex:required_version = "([^"]+)"   a regex, returning its first capture group
+
+An example of this would be:
+
+cloud-platform-git-xargs query --path versions.tf \
+							   --extract hcl:terraform.required_version \
+							   --repository "cloud-platform-terraform"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// You must set a GITHUB_OAUTH_TOKEN environment variable
+		token := os.Getenv("GITHUB_OAUTH_TOKEN")
+		if token == "" {
+			return errors.New("you must have the GITHUB_OAUTH_TOKEN env var")
+		}
+
+		extractor, err := query.ParseExtractor(extractSpec)
+		if err != nil {
+			return err
+		}
+		if len(queryPaths) == 0 {
+			return errors.New("at least one path is needed")
+		}
+		if outputFormat != "table" && outputFormat != "json" {
+			return fmt.Errorf("unknown output format %q, want table or json", outputFormat)
+		}
+
+		client := GitHubClient(token)
+
+		fmt.Fprintln(os.Stderr, "Fetching repositories...")
+
+		repos, err := get.FetchRepositories(client, org, repos, file)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, "Repositories fetched.")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		results := &query.Report{}
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
+
+			result := &query.Repo{Name: repo.GetName()}
+			results.Repos = append(results.Repos, result)
+
+			err := queryRepo(ctx, repo, client, extractor, result)
+			if err != nil {
+				result.Error = err.Error()
+				fmt.Fprintf(os.Stderr, "%s: %s\n", repo.GetName(), err)
+			}
+		}
+
+		if outputFormat == "json" {
+			err = results.WriteJSON(os.Stdout)
+		} else {
+			err = results.Print(os.Stdout)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return errors.New("query interrupted")
+		}
+		if failed := results.Errors(); failed > 0 {
+			return fmt.Errorf("%d of %d repositories couldn't be queried", failed, len(repos))
+		}
+		return nil
+	},
+}
+
+// queryRepo reads the matching files of a repository and records the values extracted
+// from each in result.
+func queryRepo(ctx context.Context, repo *github.Repository, client *github.Client, extractor query.Extractor, result *query.Repo) error {
+	files, err := query.Fetch(ctx, client, repo, queryPaths)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		values, err := extractor.Extract(f.Path, f.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		result.Add(f.Path, values)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringVarP(&extractSpec, "extract", "x", "", "what to extract from each file, as kind:expression i.e. hcl:terraform.required_version")
+	queryCmd.Flags().StringSliceVarP(&queryPaths, "path", "p", nil, "globs of the files to read, matched against their path or name i.e. versions.tf,*.tf")
+	queryCmd.Flags().StringVarP(&repos, "repository", "r", "", "a blob of the repository name i.e. cloud-platform-terraform")
+	queryCmd.Flags().StringVarP(&org, "organisation", "o", "ministryofjustice", "organisation of the repository i.e. ministryofjustice")
+	queryCmd.Flags().StringVarP(&file, "file", "f", "", "path to file containing list of repositories to process.")
+	queryCmd.Flags().StringVar(&outputFormat, "output", "table", "how to print the results, table or json.")
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v35/github"
+	"github.com/spf13/cobra"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/get"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/git"
+)
+
+// Passed via flags on the revert command only
+var title string
+
+// revertCmd represents the revert command. It finds the merged pull requests of a
+// campaign and opens a pull request reverting each of them.
+var revertCmd = &cobra.Command{
+	Use:   "revert",
+	Short: "Reverts the merged pull requests of a campaign on a collection of repositories.",
+	Long: `Given a GitHub organisation, a blob of repository names and the branch a
+campaign was run with, find every merged pull request raised from that branch,
+revert its merge commit in a fresh clone and open a pull request named
+"Revert: <original title>" linking back to the original.
+
+An example of this would be:
+
+cloud-platform-git-xargs revert --branch "update-tf-action" \
+								--title "Upgrade Terraform" \
+								--organisation "github" \
+								--repository "github"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// You must set a GITHUB_OAUTH_TOKEN environment variable
+		token := os.Getenv("GITHUB_OAUTH_TOKEN")
+		if token == "" {
+			return errors.New("you must have the GITHUB_OAUTH_TOKEN env var")
+		}
+
+		if title == "" {
+			return errors.New("title can't be empty, it picks the campaign to revert")
+		}
+
+		client := GitHubClient(token)
+
+		fmt.Println("Fetching repositories...")
+
+		repos, err := get.FetchRepositories(client, org, repos, file)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Repositories fetched.")
+		// A repository that can't be reverted, such as one with a conflict, is reported
+		// and left for manual work while the rest carry on.
+		failed := 0
+		for _, repo := range repos {
+			err = revertRepo(repo, client)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d repositories failed to revert", failed, len(repos))
+		}
+		return nil
+	},
+}
+
+func revertRepo(repo *github.Repository, client *github.Client) error {
+	prs, err := get.MergedPullRequests(client, repo, branch, title)
+	if err != nil {
+		return fmt.Errorf("error fetching pull requests for %s: %w", repo.GetName(), err)
+	}
+
+	if len(prs) == 0 {
+		fmt.Println("No merged pull requests to revert in", repo.GetName())
+		return nil
+	}
+
+	repoDir, localRepo, err := git.Clone(context.Background(), repo, client, git.CloneOptions{})
+	if err != nil {
+		return fmt.Errorf("error cloning repository: %w", err)
+	}
+
+	// Each revert branches from the default branch so the pull requests stand alone.
+	ref, err := localRepo.Head()
+	if err != nil {
+		return fmt.Errorf("error getting HEAD ref: %w", err)
+	}
+
+	tree, err := localRepo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting worktree: %w", err)
+	}
+
+	for _, pr := range prs {
+		revertBranch := fmt.Sprintf("revert-%s-%d", branch, pr.GetNumber())
+
+		_, err = git.Checkout(client, revertBranch, ref, tree, repo, localRepo)
+		if err != nil {
+			return fmt.Errorf("error creating local branch: %w", err)
+		}
+
+		message := fmt.Sprintf("Revert %q\n\nThis reverts commit %s.", pr.GetTitle(), pr.GetMergeCommitSHA())
+		_, err = git.Revert(localRepo, tree, plumbing.NewHash(pr.GetMergeCommitSHA()), message)
+		if err != nil {
+			return fmt.Errorf("error reverting #%d in %s (%s): %w", pr.GetNumber(), repo.GetName(), repoDir, err)
+		}
+
+		err = git.Push(context.Background(), localRepo, repo)
+		if err != nil {
+			return fmt.Errorf("error pushing changes to %s: %w", repo.GetName(), err)
+		}
+
+		body := fmt.Sprintf("Reverts #%d (%s).", pr.GetNumber(), pr.GetHTMLURL())
+		revert, err := git.CreatePullRequest(client, repo, revertBranch, "Revert: "+pr.GetTitle(), body, false)
+		if err != nil {
+			return fmt.Errorf("error creating pull request in %s: %w", repo.GetName(), err)
+		}
+
+		fmt.Println("Created", revert.GetHTMLURL())
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(revertCmd)
+
+	revertCmd.Flags().StringVarP(&repos, "repository", "r", "", "a blob of the repository name i.e. cloud-platform-terraform")
+	revertCmd.Flags().StringVarP(&org, "organisation", "o", "ministryofjustice", "organisation of the repository i.e. ministryofjustice")
+	revertCmd.Flags().StringVarP(&file, "file", "f", "", "path to file containing list of repositories to process.")
+	revertCmd.Flags().StringVarP(&branch, "branch", "b", "update-tf-action", "the branch the campaign's pull requests were raised from.")
+	revertCmd.Flags().StringVarP(&title, "title", "t", "", "revert pull requests whose title contains this i.e. the campaign's commit message. Required, so only one campaign raised from the branch is reverted.")
+	revertCmd.MarkFlagRequired("title")
+}
@@ -16,16 +16,28 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	gogit "github.com/go-git/go-git/v5"
 	"github.com/google/go-github/v35/github"
 	"github.com/spf13/cobra"
 
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/campaign"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/codeowners"
 	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
 	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/get"
 	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/git"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/pr"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/report"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
 )
 
 // All passed via flags
@@ -33,7 +45,28 @@ var (
 	command, message string
 	repos, org       string
 	skipCommit, loop bool
-	file             string
+	file, branch     string
+
+	prTitle, prBody, prBodyFile string
+	prOptions                   pr.Options
+	codeownerReviewers          bool
+	closeStale                  bool
+	staleComment                string
+	verbose                     bool
+	reportFile                  string
+	timeout                     time.Duration
+	envPairs                    []string
+	script, specFile            string
+	transformName               string
+	templateCommand             bool
+	transformArgs               []string
+	steps                       []campaign.Step
+	dirFilter                   execute.DirFilter
+	dirParallel                 int
+	continueOnDirError          bool
+	exitCodes                   execute.ExitCodes
+	commitOnFailure             bool
+	cloneOptions                git.CloneOptions
 )
 
 // runCmd represents the run command. This command, with arguments,
@@ -58,6 +91,43 @@ cloud-platform-git-xargs run --command "touch blankfile" \
 			return errors.New("you must have the GITHUB_OAUTH_TOKEN env var")
 		}
 
+		// Pull request body templates can be passed inline or read from a file
+		if prBodyFile != "" {
+			if prBody != "" {
+				return errors.New("cannot use both pr-body and pr-body-file flags")
+			}
+			data, err := os.ReadFile(prBodyFile)
+			if err != nil {
+				return fmt.Errorf("error reading pr body file: %w", err)
+			}
+			prBody = string(data)
+		}
+
+		// Catch a malformed template before any repository is cloned, rather than once per repository
+		err := pr.Parse(prTitle, prBody)
+		if err != nil {
+			return fmt.Errorf("error parsing pull request template: %w", err)
+		}
+
+		err = execute.ValidateEnv(envPairs)
+		if err != nil {
+			return err
+		}
+
+		steps, err = loadSteps()
+		if err != nil {
+			return err
+		}
+
+		// Repositories in a list file can override the pull request options
+		var overrides map[string]map[string]string
+		if file != "" {
+			overrides, err = get.RepoOverrides(file)
+			if err != nil {
+				return fmt.Errorf("error reading repository overrides: %w", err)
+			}
+		}
+
 		// Create GH client using your personal access token
 		client := GitHubClient(token)
 
@@ -71,21 +141,75 @@ cloud-platform-git-xargs run --command "touch blankfile" \
 
 		fmt.Println("Repositories fetched.")
 		// Loop over all repositories, run a command, commit the change and
-		// create a pull request. Decided to use errgroup instead of waitgroups
-		// as it was easier to understand.
+		// create a pull request. A failing repository is recorded in the report
+		// and the run carries on with the next.
+		//
+		// SIGINT or SIGTERM cancels the repository in flight and skips the rest,
+		// a second signal exits immediately.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+
+		results := &report.Report{}
 		for _, repo := range repos {
-			err = processRepo(repo, client)
+			result := &report.Repo{Name: repo.GetName(), Status: report.Success}
+			results.Add(result)
+
+			if ctx.Err() != nil {
+				result.Status = report.Skipped
+				continue
+			}
+
+			err := processRepo(ctx, repo, client, overrides[repo.GetName()], result)
+			switch {
+			case err != nil && ctx.Err() != nil:
+				result.Status = report.Cancelled
+				result.Error = err.Error()
+				markInterrupted(result)
+			case err != nil:
+				result.Status = report.Failed
+				result.Error = err.Error()
+				fmt.Fprintf(os.Stderr, "%s: %s\n", repo.GetName(), err)
+			}
+		}
+
+		fmt.Println()
+		err = results.Print(os.Stdout)
+		if err != nil {
+			return err
+		}
+
+		if reportFile != "" {
+			err = results.WriteJSON(reportFile)
 			if err != nil {
-				return err
+				return fmt.Errorf("error writing report: %w", err)
 			}
 		}
+
+		if ctx.Err() != nil {
+			return errors.New("run interrupted, cancelled clones are marked in tmp/")
+		}
+		if failed := results.Count(report.Failed); failed > 0 {
+			return fmt.Errorf("%d of %d repositories failed", failed, len(repos))
+		}
 		return nil
 	},
 }
 
-func processRepo(repo *github.Repository, client *github.Client) error {
+// processRepo clones a repository, executes the command on it and raises a pull request
+// with the changes, recording the outcome in result.
+func processRepo(ctx context.Context, repo *github.Repository, client *github.Client, overrides map[string]string, result *report.Repo) error {
+	opts, err := prOptions.Override(overrides)
+	if err != nil {
+		return fmt.Errorf("error in pull request options: %w", err)
+	}
+
 	// Clone repository to local disk
-	repoDir, localRepo, err := git.Clone(repo, client)
+	repoDir, localRepo, err := git.Clone(ctx, repo, client, cloneOptions)
+	result.CloneDir = repoDir
 	if err != nil {
 		return fmt.Errorf("error cloning repository: %w", err)
 	}
@@ -103,35 +227,190 @@ func processRepo(repo *github.Repository, client *github.Client) error {
 	}
 
 	// Create local branch
-	_, err = git.Checkout(client, ref, tree, repo, localRepo)
+	_, err = git.Checkout(client, branch, ref, tree, repo, localRepo)
 	if err != nil {
 		return fmt.Errorf("error creating local branch: %w", err)
 	}
 
-	// Execute command
-	err = execute.Command(repoDir, command, tree, loop)
+	// Capture the command's output in a log file next to the clone, and stream
+	// it to the terminal when verbose.
+	result.LogFile = repoDir + ".log"
+	logFile, err := os.Create(result.LogFile)
 	if err != nil {
-		return fmt.Errorf("error executing command: %w", err)
+		return fmt.Errorf("error creating log file: %w", err)
+	}
+	defer logFile.Close()
+
+	var output io.Writer = logFile
+	if verbose {
+		output = io.MultiWriter(logFile, execute.NewPrefixWriter(os.Stdout, "["+repo.GetName()+"] "))
+	}
+
+	// Execute the command, script or campaign steps
+	commandLine, err := runSteps(ctx, client, repo, repoDir, tree, output, result)
+	switch {
+	case errors.Is(err, errSkipped):
+		return skipRepo(client, repo, result)
+	case err != nil && commitOnFailure && ctx.Err() == nil:
+		// Keep whatever the command managed to change, flagging the repository as partial
+		result.Status = report.Partial
+		result.Error = err.Error()
+		fmt.Fprintf(os.Stderr, "%s: %s, committing partial changes\n", repo.GetName(), err)
+	case err != nil:
+		return err
 	}
 
 	// As long as skipCommit isn't true, stage, push and pr changes
 	if !skipCommit {
-		err = git.PushChanges(client, "update-tf-action", tree, repoDir, message, localRepo, repo)
+		pull, err := pushChanges(ctx, client, tree, localRepo, repo, commandLine, opts)
+		if errors.Is(err, git.ErrNoChanges) && result.Status == report.Partial {
+			return errors.New(result.Error)
+		}
+		if errors.Is(err, git.ErrNoChanges) {
+			result.Status = report.NoChanges
+			if closeStale {
+				return closeStalePullRequest(client, repo, result)
+			}
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("error pushing changes to %s: %w", repo.GetName(), err)
 		}
+		result.PullRequest = pull.GetHTMLURL()
+	}
+	return nil
+}
+
+// pushChanges commits the changes made by the command, pushes the branch and creates
+// a pull request titled and described by the rendered pull request templates, then
+// labels it and routes it to the right people.
+func pushChanges(ctx context.Context, client *github.Client, tree *gogit.Worktree, localRepo *gogit.Repository, repo *github.Repository, commandLine string, opts pr.Options) (*github.PullRequest, error) {
+	commit, err := git.Commit(localRepo, tree, message)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := pr.NewData(repo, commandLine, branch, message, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	title, body, err := pr.Render(prTitle, prBody, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering pull request template: %w", err)
+	}
+
+	err = git.Push(ctx, localRepo, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pull, err := git.CreatePullRequest(client, repo, branch, title, body, opts.Draft)
+	if err != nil {
+		return nil, err
+	}
+
+	if codeownerReviewers {
+		rules, err := codeowners.Load(commit)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CODEOWNERS: %w", err)
+		}
+		users, teams := rules.Reviewers(data.Files, repo.GetOwner().GetLogin())
+		opts = opts.AddReviewers(users, teams, pull.GetUser().GetLogin())
 	}
+
+	return pull, pr.Apply(client, repo, pull, opts)
+}
+
+// markInterrupted writes a marker next to the clone of a repository whose processing was
+// cancelled, so the partial state left on disk isn't mistaken for a finished run.
+func markInterrupted(result *report.Repo) {
+	if result.CloneDir == "" {
+		return
+	}
+
+	marker := result.CloneDir + ".interrupted"
+	note := fmt.Sprintf("processing of %s was interrupted, %s may be incomplete\n", result.Name, result.CloneDir)
+	if err := os.WriteFile(marker, []byte(note), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error marking clone as interrupted: %s\n", result.Name, err)
+	}
+}
+
+// skipRepo records a repository where every execution exited with a skip code. There's
+// nothing to do, so with --close-stale the campaign's open pull request is closed as it is
+// when the command makes no changes.
+func skipRepo(client *github.Client, repo *github.Repository, result *report.Repo) error {
+	result.Status = report.Skipped
+	if closeStale && !skipCommit {
+		return closeStalePullRequest(client, repo, result)
+	}
+	return nil
+}
+
+// closeStalePullRequest closes the campaign's open pull request on a repository the
+// command no longer changes, i.e. because it has been fixed upstream since.
+func closeStalePullRequest(client *github.Client, repo *github.Repository, result *report.Repo) error {
+	pull, err := get.OpenPullRequest(client, repo, branch)
+	if err != nil {
+		return fmt.Errorf("error fetching pull requests for %s: %w", repo.GetName(), err)
+	}
+
+	if pull == nil {
+		return nil
+	}
+
+	err = pr.Close(client, repo, pull, staleComment)
+	if err != nil {
+		return fmt.Errorf("error closing stale pull request in %s: %w", repo.GetName(), err)
+	}
+
+	result.Status = report.ClosedStale
+	result.PullRequest = pull.GetHTMLURL()
 	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(runCmd)
 
-	runCmd.Flags().StringVarP(&command, "command", "c", "", "the command you'd like to execute i.e. touch file")
+	runCmd.Flags().StringVarP(&command, "command", "c", "", "the command you'd like to execute i.e. touch file.")
+	runCmd.Flags().BoolVar(&templateCommand, "template-command", false, "render the command as a Go template per repository i.e. echo {{.Repo.Name}}")
+	runCmd.Flags().StringVar(&script, "script", "", "path to a local script to copy into each repository and execute, instead of a command.")
+	runCmd.Flags().StringVar(&transformName, "transform", "", "the name of a transformation to run on each repository instead of a command. Built in: "+strings.Join(transform.Names(), ", ")+".")
+	runCmd.Flags().StringArrayVar(&transformArgs, "transform-args", nil, "key=value arguments for the transformation i.e. path=terraform.required_version. Can be repeated.")
+	runCmd.Flags().StringVar(&specFile, "spec", "", "path to a campaign file listing the steps to run on each repository, instead of a command.")
 	runCmd.Flags().StringVarP(&repos, "repository", "r", "", "a blob of the repository name i.e. cloud-platform-terraform")
 	runCmd.Flags().StringVarP(&org, "organisation", "o", "ministryofjustice", "organisation of the repository i.e. ministryofjustice")
 	runCmd.Flags().StringVarP(&message, "commit", "m", "perform command on repository", "the commit message you'd like to make")
 	runCmd.Flags().BoolVarP(&skipCommit, "skip-commit", "s", false, "whether or not you want to create a commit and PR.")
 	runCmd.Flags().BoolVarP(&loop, "loop-dir", "l", false, "if you wish to execute the command on every directory in repository.")
 	runCmd.Flags().StringVarP(&file, "file", "f", "", "path to file containing list of repositories to process.")
+	runCmd.Flags().StringSliceVar(&dirFilter.Include, "dir-include", nil, "with loop-dir, only execute in directories whose path or name matches these globs i.e. namespaces/*")
+	runCmd.Flags().StringSliceVar(&dirFilter.Exclude, "dir-exclude", nil, "with loop-dir, skip directories, and everything below them, whose path or name matches these globs i.e. .terraform")
+	runCmd.Flags().IntVar(&dirFilter.MaxDepth, "max-depth", 0, "with loop-dir, how many levels of directories to walk. No limit by default.")
+	runCmd.Flags().IntVar(&dirParallel, "dir-parallel", 1, "with loop-dir, how many directories to execute the command in at once.")
+	runCmd.Flags().BoolVar(&continueOnDirError, "continue-on-dir-error", false, "with loop-dir, carry on executing in the remaining directories when one fails. The repository still fails.")
+	runCmd.Flags().StringSliceVar(&dirFilter.Contains, "dir-contains", nil, "with loop-dir, only execute in directories containing a file matching these globs i.e. *.tf")
+	runCmd.Flags().StringVar(&prTitle, "pr-title", "", "a Go template for the pull request title i.e. {{.Repo.Name}}: {{.Message}}. Defaults to the commit message.")
+	runCmd.Flags().StringVar(&prBody, "pr-body", "", "a Go template for the pull request body. Variables: .Repo, .Command, .CampaignID, .Message, .Files, .Diffstat")
+	runCmd.Flags().StringVar(&prBodyFile, "pr-body-file", "", "path to a file containing the pull request body template.")
+	runCmd.Flags().StringSliceVar(&prOptions.Labels, "label", nil, "labels to add to the pull request.")
+	runCmd.Flags().StringSliceVar(&prOptions.Reviewers, "reviewer", nil, "users to request a pull request review from.")
+	runCmd.Flags().StringSliceVar(&prOptions.TeamReviewers, "team-reviewer", nil, "teams to request a pull request review from i.e. webops.")
+	runCmd.Flags().StringSliceVar(&prOptions.Assignees, "assignee", nil, "users to assign the pull request to.")
+	runCmd.Flags().StringVar(&prOptions.Milestone, "milestone", "", "the milestone, by number or title, to add the pull request to.")
+	runCmd.Flags().BoolVar(&prOptions.Draft, "draft", false, "whether to open the pull request as a draft.")
+	runCmd.Flags().BoolVar(&codeownerReviewers, "reviewers-from-codeowners", false, "request reviews from the CODEOWNERS of the changed files.")
+	runCmd.Flags().BoolVar(&closeStale, "close-stale", false, "close the campaign's open pull request and delete its branch when the command no longer changes a repository.")
+	runCmd.Flags().StringVar(&staleComment, "stale-comment", "Closing as this campaign no longer makes any changes to the repository.", "the comment left on stale pull requests when closing them.")
+	runCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "stream each command's output, prefixed with the repository name.")
+	runCmd.Flags().StringVar(&reportFile, "report", "", "path to write a JSON report of the run, including each command's output, to.")
+	runCmd.Flags().IntSliceVar(&exitCodes.Success, "success-exit-codes", []int{0}, "exit codes of the command that count as success.")
+	runCmd.Flags().IntSliceVar(&exitCodes.Skip, "skip-exit-codes", nil, "exit codes of the command meaning there's nothing to do. A repository where every execution skips isn't committed.")
+	runCmd.Flags().BoolVar(&commitOnFailure, "commit-on-failure", false, "commit and raise a pull request for whatever changes a failing command made, marking the repository as partial.")
+	runCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long each execution of the command may take before it's killed i.e. 10m. No limit by default.")
+	runCmd.Flags().StringArrayVarP(&envPairs, "env", "e", nil, "KEY=VALUE environment variables to set for the command. Can be repeated.")
+	runCmd.Flags().IntVar(&cloneOptions.Depth, "depth", 0, "how many commits of history to clone. The full history by default.")
+	runCmd.Flags().BoolVar(&cloneOptions.SingleBranch, "single-branch", false, "clone only the default branch of each repository.")
+	runCmd.Flags().StringSliceVar(&cloneOptions.Sparse, "sparse", nil, "only check out these directories, along with the files at the root of the repository i.e. namespaces,.github")
+	runCmd.Flags().StringVarP(&branch, "branch", "b", "update-tf-action", "the branch to push changes to. This identifies the campaign's pull requests.")
 }
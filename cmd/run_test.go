@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/report"
+)
+
+// TestSkipRepo checks a repository where every execution skipped has its stale pull
+// request closed with --close-stale, and is only marked skipped without it.
+func TestSkipRepo(t *testing.T) {
+	repo := &github.Repository{
+		Name:  github.String("cloud-platform-terraform-rds"),
+		Owner: &github.User{Login: github.String("ministryofjustice")},
+	}
+
+	closed := false
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepo,
+			[]github.PullRequest{{
+				Number:  github.Int(7),
+				HTMLURL: github.String("https://github.com/ministryofjustice/cloud-platform-terraform-rds/pull/7"),
+				Head:    &github.PullRequestBranch{Ref: github.String("update-tf-action")},
+			}},
+		),
+		mock.WithRequestMatch(mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber, github.IssueComment{}),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				closed = true
+				_, _ = w.Write([]byte(`{}`))
+			}),
+		),
+		// The mock's own pattern doesn't match refs containing a slash, as branch refs do.
+		mock.WithRequestMatchHandler(
+			mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/git/refs/heads/{branch}", Method: "DELETE"},
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		),
+	))
+
+	defer func(stale bool) { closeStale = stale }(closeStale)
+
+	closeStale = false
+	result := &report.Repo{}
+	if err := skipRepo(client, repo, result); err != nil {
+		t.Fatalf("skipRepo() error = %v", err)
+	}
+	if result.Status != report.Skipped || closed {
+		t.Errorf("skipRepo() without close-stale = %s, closed %v; want skipped and left open", result.Status, closed)
+	}
+
+	closeStale = true
+	result = &report.Repo{}
+	if err := skipRepo(client, repo, result); err != nil {
+		t.Fatalf("skipRepo() error = %v", err)
+	}
+	if result.Status != report.ClosedStale || !closed {
+		t.Errorf("skipRepo() with close-stale = %s, closed %v; want the pull request closed", result.Status, closed)
+	}
+	if result.PullRequest == "" {
+		t.Error("skipRepo() didn't record the closed pull request")
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/google/go-github/v35/github"
+
+	// Register the built-in transformations
+	_ "github.com/ministryofjustice/cloud-platform-git-xargs/internal/builtin"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/campaign"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/render"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/report"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// errSkipped is returned by runSteps when every execution exited with a skip code.
+var errSkipped = errors.New("every execution exited with a skip code")
+
+// loadSteps returns the steps to run on each repository, from either the campaign file,
+// the script, the transformation or the command passed via flags.
+func loadSteps() ([]campaign.Step, error) {
+	set := 0
+	for _, flag := range []string{command, script, specFile, transformName} {
+		if flag != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("only one of command, script, spec and transform flags can be used")
+	}
+	if command == "" && templateCommand {
+		return nil, errors.New("template-command can only be used with the command flag")
+	}
+	if transformName == "" && len(transformArgs) > 0 {
+		return nil, errors.New("transform-args can only be used with the transform flag")
+	}
+
+	switch {
+	case specFile != "":
+		spec, err := campaign.Load(specFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range spec.Steps {
+			if step.Transform == "" {
+				continue
+			}
+			if _, err := transform.Lookup(step.Transform); err != nil {
+				return nil, fmt.Errorf("%s: %w", step.Name, err)
+			}
+		}
+		return spec.Steps, nil
+	case transformName != "":
+		if _, err := transform.Lookup(transformName); err != nil {
+			return nil, err
+		}
+		args, err := transform.ParseArgs(transformArgs)
+		if err != nil {
+			return nil, err
+		}
+		return []campaign.Step{{Name: transformName, Transform: transformName, Args: args}}, nil
+	case script != "":
+		path, err := filepath.Abs(script)
+		if err != nil {
+			return nil, err
+		}
+		return []campaign.Step{{Name: filepath.Base(script), Script: path, Loop: loop}}, nil
+	default:
+		return []campaign.Step{{Name: "command", Command: command, Template: templateCommand, Loop: loop}}, nil
+	}
+}
+
+// runSteps executes each step on a clone in order, recording their output in result. A
+// failing step stops the rest unless it continues on failure. It returns a description
+// of what was run for the pull request, even on failure, or errSkipped if there was
+// nothing to do.
+func runSteps(ctx context.Context, client *github.Client, repo *github.Repository, repoDir string, tree *gogit.Worktree, output io.Writer, result *report.Repo) (string, error) {
+	// Tell the commands where they're running, along with any variables from the user
+	env, err := execute.RepoEnv(repo, repoDir, skipCommit)
+	if err != nil {
+		return "", fmt.Errorf("error building environment: %w", err)
+	}
+	env = append(env, envPairs...)
+
+	var ran []string
+	for _, step := range steps {
+		if len(steps) > 1 {
+			fmt.Fprintf(output, "==> %s\n", step.Name)
+		}
+
+		if step.Transform != "" {
+			summary, err := runTransform(ctx, client, step, repo, repoDir, tree, output, result)
+			ran = append(ran, summary)
+			if err != nil && (!step.ContinueOnFailure || ctx.Err() != nil) {
+				return strings.Join(ran, "\n"), fmt.Errorf("error executing %s: %w", step.Name, err)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s failed, continuing: %s\n", repo.GetName(), step.Name, err)
+			}
+			continue
+		}
+
+		commandLine, err := stepCommand(step, repo, repoDir)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", step.Name, err)
+		}
+
+		if step.Script != "" {
+			ran = append(ran, filepath.Base(step.Script))
+		} else {
+			ran = append(ran, commandLine)
+		}
+
+		results, err := execute.Command(ctx, repoDir, commandLine, tree, execute.Options{
+			Dir:             step.Dir,
+			Loop:            step.Loop,
+			Filter:          dirFilter,
+			Parallel:        dirParallel,
+			ContinueOnError: continueOnDirError,
+			ExitCodes:       exitCodes,
+			Output:          output,
+			Timeout:         timeout,
+			Env:             env,
+		})
+		result.Output = append(result.Output, results...)
+		if err != nil && (!step.ContinueOnFailure || ctx.Err() != nil) {
+			return strings.Join(ran, "\n"), fmt.Errorf("error executing %s: %w", step.Name, err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s failed, continuing: %s\n", repo.GetName(), step.Name, err)
+		}
+	}
+
+	// A transformation that changed files means there's something to commit regardless
+	transformed := false
+	for _, t := range result.Transforms {
+		transformed = transformed || len(t.Files) > 0
+	}
+	if !transformed && execute.Skipped(result.Output) {
+		return strings.Join(ran, "\n"), errSkipped
+	}
+
+	return strings.Join(ran, "\n"), nil
+}
+
+// runTransform runs a transformation step on the clone, recording what it changed in
+// result. It returns the transformation's summary for the pull request.
+func runTransform(ctx context.Context, client *github.Client, step campaign.Step, repo *github.Repository, repoDir string, tree *gogit.Worktree, output io.Writer, result *report.Repo) (string, error) {
+	t, err := transform.Lookup(step.Transform)
+	if err != nil {
+		return step.Transform, err
+	}
+
+	changes, err := t.Transform(ctx, &transform.Input{
+		Dir:    filepath.Join(repoDir, step.Dir),
+		Repo:   repo,
+		Tree:   tree,
+		Client: client,
+		Args:   step.Args,
+		DryRun: skipCommit,
+	})
+	if changes == nil {
+		changes = &transform.Result{Name: step.Transform}
+	}
+	result.Transforms = append(result.Transforms, *changes)
+
+	for _, file := range changes.Files {
+		if n, ok := changes.Matches[file]; ok {
+			fmt.Fprintf(output, "%s: changed %s (%d matches)\n", step.Transform, file, n)
+			continue
+		}
+		fmt.Fprintf(output, "%s: changed %s\n", step.Transform, file)
+	}
+	if skipCommit && changes.Summary != "" {
+		fmt.Fprintln(output, changes.Summary)
+	}
+	if changes.Summary == "" {
+		return step.Transform, err
+	}
+	return changes.Summary, err
+}
+
+// stepCommand returns the shell command line for a step, installing its script into the
+// clone or, if it's a template, rendering its command for the repository.
+func stepCommand(step campaign.Step, repo *github.Repository, repoDir string) (string, error) {
+	if step.Script != "" {
+		path, err := execute.InstallScript(repoDir, step.Script)
+		if err != nil {
+			return "", fmt.Errorf("error installing script: %w", err)
+		}
+		return render.ShellQuote(path), nil
+	}
+
+	if !step.Template {
+		return step.Command, nil
+	}
+
+	commandLine, err := render.Render("command", step.Command, render.RepoData{Repo: repo})
+	if err != nil {
+		return "", fmt.Errorf("error rendering command: %w", err)
+	}
+	return commandLine, nil
+}
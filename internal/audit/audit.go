@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
+)
+
+// Statuses a repository can be audited with.
+const (
+	// Pass means the check exited successfully everywhere it ran.
+	Pass = "pass"
+	// Fail means the check ran but exited with a failure, i.e. grep finding nothing.
+	Fail = "fail"
+	// Error means the check couldn't be run, i.e. the clone failed or it timed out.
+	Error = "error"
+)
+
+// Repo is the outcome of checking a single repository.
+type Repo struct {
+	Name   string           `json:"name"`
+	Status string           `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Output []execute.Result `json:"output,omitempty"`
+}
+
+// Stdout returns the check's output in every directory it ran, trimmed. In loop mode
+// each line is prefixed with its directory.
+func (r *Repo) Stdout() []string {
+	var lines []string
+	for _, result := range r.Output {
+		out := strings.TrimSpace(result.Stdout)
+		if out == "" {
+			continue
+		}
+		for _, line := range strings.Split(out, "\n") {
+			if len(r.Output) > 1 {
+				line = result.Dir + ": " + line
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Report collects the outcome of every repository audited.
+type Report struct {
+	Repos []*Repo `json:"repositories"`
+}
+
+// Count returns how many repositories were audited with the given status.
+func (r *Report) Count(status string) int {
+	n := 0
+	for _, repo := range r.Repos {
+		if repo.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes a table of every repository's status and output to w. Output spanning
+// several lines continues on the rows below the repository.
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tSTATUS\tOUTPUT")
+	for _, repo := range r.Repos {
+		lines := repo.Stdout()
+		if repo.Error != "" {
+			lines = append(lines, strings.SplitN(repo.Error, "\n", 2)[0])
+		}
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", repo.Name, repo.Status, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(tw, "\t\t%s\n", line)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the full report, including the output of every execution, to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
+)
+
+// TestPrint checks multi-line output continues below its repository and errors are shown.
+func TestPrint(t *testing.T) {
+	r := &Report{Repos: []*Repo{
+		{Name: "cloud-platform-terraform-s3-bucket", Status: Pass, Output: []execute.Result{
+			{Dir: "examples", Stdout: "required_version = \">= 0.14\"\n"},
+			{Dir: "modules", Stdout: "\n"},
+			{Dir: ".", Stdout: "required_version = \">= 1.2.5\"\n"},
+		}},
+		{Name: "cloud-platform-terraform-irsa", Status: Fail, Output: []execute.Result{{Dir: ".", ExitCode: 1}}},
+		{Name: "cloud-platform-terraform-rds", Status: Error, Error: "error cloning repository: not found\nmore"},
+	}}
+
+	var buf bytes.Buffer
+	if err := r.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `REPOSITORY                          STATUS  OUTPUT
+cloud-platform-terraform-s3-bucket  pass    examples: required_version = ">= 0.14"
+                                            .: required_version = ">= 1.2.5"
+cloud-platform-terraform-irsa       fail    
+cloud-platform-terraform-rds        error   error cloning repository: not found
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", got, want)
+	}
+
+	if r.Count(Fail) != 1 {
+		t.Errorf("Count(Fail) = %d, want 1", r.Count(Fail))
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"status": "error"`) {
+		t.Errorf("WriteJSON() = %s", buf.String())
+	}
+}
@@ -0,0 +1,255 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// commitSHA matches a full commit SHA an action may be pinned to.
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ActionsBump upgrades the uses: owner/action@ref references in the GitHub Actions
+// workflows of a clone, editing them in place so the rest of each workflow is left alone.
+// Its arguments are:
+//
+//	actions comma separated owner/action references to bump, every action by default
+//	version the ref to bump to, or "latest" for the latest release, by default. With latest
+//	        a ref keeps its precision, i.e. v3 becomes v4 rather than v4.1.2, is never
+//	        downgraded, and refs that aren't versions, i.e. main, are left alone
+//	pin     pin references to the full commit SHA of the ref with a trailing version comment,
+//	        i.e. actions/checkout@8e5e7e5ab8b370d6c329ec480221332ada57f0ab # v3.5.3. References
+//	        already pinned stay pinned. False by default
+//	files   comma separated globs of the workflows, ".github/workflows/*.yml,.github/workflows/*.yaml"
+//	        by default
+func ActionsBump(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	pin, err := in.Args.Bool("pin", false)
+	if err != nil {
+		return nil, err
+	}
+
+	only := make(map[string]bool)
+	for _, action := range in.Args.List("actions") {
+		only[strings.ToLower(action)] = true
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", ".github/workflows/*.yml", ".github/workflows/*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	bump := &actionsBump{in: in, version: in.Args.Get("version", "latest"), pin: pin, only: only, changes: make(map[string]bool)}
+	result := &transform.Result{Name: "actions-bump"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := bump.file(ctx, filepath.Join(in.Dir, rel))
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	changes := make([]string, 0, len(bump.changes))
+	for change := range bump.changes {
+		changes = append(changes, change)
+	}
+	sort.Strings(changes)
+	result.Summary = fmt.Sprintf("Bumped GitHub Actions in %d file(s)", len(result.Files))
+	if len(changes) > 0 {
+		result.Summary += ":\n" + strings.Join(changes, "\n")
+	}
+	return result, nil
+}
+
+// actionsBump holds the options of an actions bump and the changes it made.
+type actionsBump struct {
+	in      *transform.Input
+	version string
+	pin     bool
+	only    map[string]bool
+	changes map[string]bool
+}
+
+// file bumps the action references in a single workflow, rewriting it only if its
+// contents changed.
+func (b *actionsBump) file(ctx context.Context, filename string) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	var uses []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("error parsing: %w", err)
+		}
+		uses = append(uses, findUses(&doc)...)
+	}
+
+	lines := strings.SplitAfter(string(src), "\n")
+	for _, node := range uses {
+		value, comment, err := b.reference(ctx, node)
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if value == "" {
+			continue
+		}
+
+		line, err := replaceScalar(lines[node.Line-1], node, value, comment)
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		lines[node.Line-1] = line
+	}
+
+	out := strings.Join(lines, "")
+	if out == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, []byte(out))
+}
+
+// reference works out the new value of a uses: node and its trailing comment when pinned.
+// It returns an empty value if the reference should be left alone.
+func (b *actionsBump) reference(ctx context.Context, node *yaml.Node) (string, string, error) {
+	// Local actions and docker images have no GitHub release to bump to
+	if strings.HasPrefix(node.Value, "./") || strings.HasPrefix(node.Value, "docker://") {
+		return "", "", nil
+	}
+	i := strings.LastIndex(node.Value, "@")
+	if i < 0 {
+		return "", "", nil
+	}
+	action, ref := node.Value[:i], node.Value[i+1:]
+
+	parts := strings.SplitN(action, "/", 3)
+	if len(parts) < 2 {
+		return "", "", nil
+	}
+	owner, repo := parts[0], parts[1]
+	if len(b.only) > 0 && !b.only[strings.ToLower(owner+"/"+repo)] {
+		return "", "", nil
+	}
+
+	// A pinned reference's version is in its trailing comment
+	current, pinned := ref, commitSHA.MatchString(ref)
+	if pinned {
+		current = strings.TrimSpace(strings.TrimPrefix(node.LineComment, "#"))
+	}
+
+	target := b.version
+	if target == "latest" {
+		// Branches and unlabelled SHAs aren't versions to compare against
+		if !semver.IsValid(semverOf(current)) {
+			return "", "", nil
+		}
+
+		tag, err := latestTag(ctx, b.in.Client, owner, repo)
+		if err != nil {
+			return "", "", err
+		}
+		target = samePrecision(current, tag)
+
+		// Not every project keeps floating tags, i.e. v4, so a missing one falls back to
+		// the release itself
+		if target != tag {
+			exists, err := tagExists(ctx, b.in.Client, owner, repo, target)
+			if err != nil {
+				return "", "", err
+			}
+			if !exists {
+				target = tag
+			}
+		}
+
+		if semver.Compare(semverOf(current), semverOf(target)) > 0 {
+			target = current
+		}
+	}
+
+	if !b.pin && !pinned {
+		if target == ref {
+			return "", "", nil
+		}
+		b.changes[fmt.Sprintf("%s %s -> %s", action, ref, target)] = true
+		return action + "@" + target, "", nil
+	}
+
+	sha, err := tagCommit(ctx, b.in.Client, owner, repo, target)
+	if err != nil {
+		return "", "", err
+	}
+	if sha == ref && target == current {
+		return "", "", nil
+	}
+	b.changes[fmt.Sprintf("%s %s -> %s (%s)", action, current, target, sha)] = true
+	return action + "@" + sha, target, nil
+}
+
+// samePrecision returns tag cut down to as many version parts as current, i.e. v4 rather
+// than v4.1.2 for a reference to v3.
+func samePrecision(current, tag string) string {
+	version := semverOf(tag)
+	if !semver.IsValid(version) || !semver.IsValid(semverOf(current)) {
+		return tag
+	}
+
+	var cut string
+	switch strings.Count(current, ".") {
+	case 0:
+		cut = semver.Major(version)
+	case 1:
+		cut = semver.MajorMinor(version)
+	default:
+		return tag
+	}
+	if !strings.HasPrefix(tag, "v") {
+		cut = strings.TrimPrefix(cut, "v")
+	}
+	return cut
+}
+
+// findUses returns every uses: value below node, from both steps and reusable workflow jobs.
+func findUses(node *yaml.Node) []*yaml.Node {
+	var uses []*yaml.Node
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "uses" && value.Kind == yaml.ScalarNode {
+				uses = append(uses, value)
+				continue
+			}
+			uses = append(uses, findUses(value)...)
+		}
+		return uses
+	}
+
+	for _, child := range node.Content {
+		uses = append(uses, findUses(child)...)
+	}
+	return uses
+}
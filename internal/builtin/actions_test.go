@@ -0,0 +1,133 @@
+package builtin
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestActionsBump checks action references are upgraded to the latest release at the
+// same precision, and pinned to commit SHAs with a version comment when asked.
+func TestActionsBump(t *testing.T) {
+	const workflow = `name: unit
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v3 # fetch the code
+      - uses: hashicorp/setup-terraform@v2.0.3
+      - uses: azure/login@v1
+      - uses: ./.github/actions/local
+      - uses: aws-actions/configure-aws-credentials@0123456789abcdef0123456789abcdef01234567 # v1.7.0
+  shared:
+    uses: ministryofjustice/workflows/.github/workflows/lint.yml@main
+`
+	const sha = "8e5e7e5ab8b370d6c329ec480221332ada57f0ab"
+
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tags := map[string]string{
+					"/repos/actions/checkout/releases/latest":                      `{"tag_name": "v4.1.1"}`,
+					"/repos/hashicorp/setup-terraform/releases/latest":             `{"tag_name": "v3.0.0"}`,
+					"/repos/aws-actions/configure-aws-credentials/releases/latest": `{"tag_name": "v4.0.1"}`,
+					"/repos/azure/login/releases/latest":                           `{"tag_name": "v2.1.0"}`,
+				}
+				_, _ = w.Write([]byte(tags[r.URL.Path]))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposCommitsByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// azure/login has no floating major tag
+				if r.URL.Path == "/repos/azure/login/commits/v2" {
+					mock.WriteError(w, http.StatusUnprocessableEntity, "No commit found for SHA: v2")
+					return
+				}
+				_, _ = w.Write([]byte(sha))
+			}),
+		),
+	))
+
+	tests := []struct {
+		name string
+		args transform.Args
+		want map[string]string
+	}{
+		{
+			name: "latest",
+			args: transform.Args{},
+			want: map[string]string{
+				"actions/checkout@v3 # fetch":                       "actions/checkout@v4 # fetch",
+				"hashicorp/setup-terraform@v2.0.3":                  "hashicorp/setup-terraform@v3.0.0",
+				"azure/login@v1":                                    "azure/login@v2.1.0",
+				"0123456789abcdef0123456789abcdef01234567 # v1.7.0": sha + " # v4.0.1",
+			},
+		},
+		{
+			name: "pinned target",
+			args: transform.Args{"actions": "actions/checkout", "version": "v4.1.1", "pin": "true"},
+			want: map[string]string{"actions/checkout@v3 # fetch the code": "actions/checkout@" + sha + " # v4.1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".github", "workflows", "unit.yml")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(workflow), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := ActionsBump(context.Background(), &transform.Input{Dir: dir, Client: client, Args: tt.args})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Files) != 1 {
+				t.Errorf("ActionsBump() files = %v, want 1", result.Files)
+			}
+
+			want := workflow
+			for old, new := range tt.want {
+				want = replaceOnce(t, want, old, new)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("ActionsBump() wrote\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestSamePrecision checks tags are cut down to the precision of the current reference.
+func TestSamePrecision(t *testing.T) {
+	tests := []struct {
+		current, tag, want string
+	}{
+		{"v3", "v4.1.2", "v4"},
+		{"v3.5", "v4.1.2", "v4.1"},
+		{"v3.5.0", "v4.1.2", "v4.1.2"},
+		{"1", "2.3.4", "2"},
+		{"main", "v4.1.2", "v4.1.2"},
+	}
+	for _, tt := range tests {
+		if got := samePrecision(tt.current, tt.tag); got != tt.want {
+			t.Errorf("samePrecision(%q, %q) = %q, want %q", tt.current, tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package builtin holds the transformations that ship with cloud-platform-git-xargs,
+// registered with the transform package when it's imported.
+package builtin
+
+import "github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+
+func init() {
+	transform.Register("actions-bump", transform.Func(ActionsBump))
+	transform.Register("docker-bump", transform.Func(DockerBump))
+	transform.Register("gomod-bump", transform.Func(GoModBump))
+	transform.Register("hcl-set", transform.Func(HCLSet))
+	transform.Register("replace", transform.Func(Replace))
+	transform.Register("sync", transform.Func(Sync))
+	transform.Register("terraform-bump", transform.Func(TerraformBump))
+	transform.Register("yaml-set", transform.Func(YAMLSet))
+}
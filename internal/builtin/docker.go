@@ -0,0 +1,181 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+var (
+	// fromLine matches a FROM instruction, capturing its flags, image and the rest, i.e. " AS build".
+	fromLine = regexp.MustCompile(`(?i)^(\s*FROM\s+)((?:--\S+\s+)*)(\S+)(.*)$`)
+	// stageName matches the name given to a build stage.
+	stageName = regexp.MustCompile(`(?i)^\s+AS\s+(\S+)`)
+	// argLine matches an ARG instruction, capturing its name and default value.
+	argLine = regexp.MustCompile(`(?i)^(\s*ARG\s+)(\w+)(?:(=)(.*?))?(\s*)$`)
+	// argReference matches a tag that is nothing but a reference to an ARG, i.e. ${VERSION}.
+	argReference = regexp.MustCompile(`^\$\{?(\w+)\}?$`)
+)
+
+// DockerBump bumps the base image of every matching Dockerfile, rewriting the tag of FROM
+// lines whose image matches. Stage names and flags are kept, and a tag that's taken from
+// an ARG, i.e. FROM ruby:${RUBY_VERSION}, has the ARG's default bumped instead. Its
+// arguments are:
+//
+//	image  the image to bump, i.e. ruby or ministryofjustice/cloud-platform-tools
+//	tag    the tag to bump to
+//	digest the digest to pin to, i.e. sha256:..., optional. Without it any existing digest
+//	       is dropped, as it would no longer match the tag
+//	files  comma separated globs of the files to edit, "Dockerfile,*.Dockerfile,Dockerfile.*"
+//	       by default
+func DockerBump(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	image, err := in.Args.Required("image")
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := in.Args.Required("tag")
+	if err != nil {
+		return nil, err
+	}
+
+	digest := in.Args.Get("digest", "")
+	if digest != "" && !strings.Contains(digest, ":") {
+		return nil, fmt.Errorf("invalid digest %q, want algorithm:hex i.e. sha256:...", digest)
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", "Dockerfile", "*.Dockerfile", "Dockerfile.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transform.Result{Name: "docker-bump"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := dockerBumpFile(filepath.Join(in.Dir, rel), image, tag, digest)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	target := image + ":" + tag
+	if digest != "" {
+		target += "@" + digest
+	}
+	result.Summary = fmt.Sprintf("Bumped base image to %s in %d file(s)", target, len(result.Files))
+	return result, nil
+}
+
+// dockerBumpFile bumps the matching FROM lines of a single Dockerfile, rewriting it only if
+// its contents changed.
+func dockerBumpFile(filename, image, tag, digest string) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.SplitAfter(string(src), "\n")
+	args := make(map[string]int)
+	stages := make(map[string]bool)
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+
+		if m := argLine.FindStringSubmatch(body); m != nil {
+			args[m[2]] = i
+			continue
+		}
+
+		m := fromLine.FindStringSubmatch(body)
+		if m == nil {
+			continue
+		}
+		prefix, flags, ref, rest := m[1], m[2], m[3], m[4]
+
+		// Earlier stages are referred to by name, not image
+		isStage := stages[strings.ToLower(ref)]
+		if s := stageName.FindStringSubmatch(rest); s != nil {
+			stages[strings.ToLower(s[1])] = true
+		}
+
+		name, current, _ := splitImage(ref)
+		if isStage || !sameImage(name, image) {
+			continue
+		}
+
+		if arg := argReference.FindStringSubmatch(current); arg != nil {
+			if digest != "" {
+				return false, fmt.Errorf("line %d: can't pin a digest when the tag comes from ARG %s", i+1, arg[1])
+			}
+			j, ok := args[arg[1]]
+			if !ok {
+				return false, fmt.Errorf("line %d: ARG %s isn't declared", i+1, arg[1])
+			}
+			lines[j] = setArgDefault(lines[j], tag)
+			continue
+		}
+		if strings.Contains(current, "$") {
+			return false, fmt.Errorf("line %d: can't bump tag %q built from ARGs", i+1, current)
+		}
+
+		newRef := name + ":" + tag
+		if digest != "" {
+			newRef += "@" + digest
+		}
+		lines[i] = prefix + flags + newRef + rest + lineEnding(line)
+	}
+
+	out := strings.Join(lines, "")
+	if out == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, []byte(out))
+}
+
+// splitImage splits an image reference into its name, tag and digest.
+func splitImage(ref string) (string, string, string) {
+	name, digest := ref, ""
+	if i := strings.Index(ref, "@"); i >= 0 {
+		name, digest = ref[:i], ref[i+1:]
+	}
+
+	tag := ""
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+// sameImage reports whether two image names refer to the same repository, ignoring the
+// implicit Docker Hub registry and library namespace.
+func sameImage(a, b string) bool {
+	normalise := func(name string) string {
+		name = strings.ToLower(name)
+		name = strings.TrimPrefix(name, "docker.io/")
+		name = strings.TrimPrefix(name, "index.docker.io/")
+		return strings.TrimPrefix(name, "library/")
+	}
+	return normalise(a) == normalise(b)
+}
+
+// setArgDefault returns an ARG line with its default value set, keeping any quotes.
+func setArgDefault(line, value string) string {
+	m := argLine.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	current := m[4]
+	for _, quote := range []string{`"`, `'`} {
+		if len(current) >= 2 && strings.HasPrefix(current, quote) && strings.HasSuffix(current, quote) {
+			value = quote + value + quote
+		}
+	}
+	return m[1] + m[2] + "=" + value + m[5] + lineEnding(line)
+}
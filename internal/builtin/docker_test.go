@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestDockerBump checks FROM lines of the matching image are bumped, keeping flags and
+// stage names, and that ARG based tags have the ARG's default bumped instead.
+func TestDockerBump(t *testing.T) {
+	const dockerfile = `ARG RUBY_VERSION="3.1.2"
+FROM --platform=linux/amd64 ruby:${RUBY_VERSION} AS build
+RUN bundle install
+
+FROM docker.io/library/alpine:3.16@sha256:bc41182d7ef5ffc53a40b044e725193bc10142a1243f395ee852a8d9730fc2ad AS runtime
+COPY --from=build /app /app
+
+FROM build
+`
+
+	tests := []struct {
+		name    string
+		args    transform.Args
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "tag and digest dropped",
+			args: transform.Args{"image": "alpine", "tag": "3.18"},
+			want: map[string]string{"alpine:3.16@sha256:bc41182d7ef5ffc53a40b044e725193bc10142a1243f395ee852a8d9730fc2ad AS runtime": "alpine:3.18 AS runtime"},
+		},
+		{
+			name: "digest",
+			args: transform.Args{"image": "library/alpine", "tag": "3.18", "digest": "sha256:abc"},
+			want: map[string]string{"alpine:3.16@sha256:bc41182d7ef5ffc53a40b044e725193bc10142a1243f395ee852a8d9730fc2ad AS runtime": "alpine:3.18@sha256:abc AS runtime"},
+		},
+		{
+			name: "arg based tag",
+			args: transform.Args{"image": "ruby", "tag": "3.2.2"},
+			want: map[string]string{`ARG RUBY_VERSION="3.1.2"`: `ARG RUBY_VERSION="3.2.2"`},
+		},
+		{name: "stage name isn't an image", args: transform.Args{"image": "build", "tag": "1"}, want: map[string]string{}},
+		{name: "digest with arg based tag", args: transform.Args{"image": "ruby", "tag": "3.2.2", "digest": "sha256:abc"}, wantErr: true},
+		{name: "no tag", args: transform.Args{"image": "ruby"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "Dockerfile")
+			if err := os.WriteFile(path, []byte(dockerfile), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := DockerBump(context.Background(), &transform.Input{Dir: dir, Args: tt.args})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DockerBump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := dockerfile
+			for old, new := range tt.want {
+				want = replaceOnce(t, want, old, new)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("DockerBump() wrote\n%s\nwant\n%s", got, want)
+			}
+			if len(result.Files) != len(tt.want) {
+				t.Errorf("DockerBump() files = %v", result.Files)
+			}
+		})
+	}
+}
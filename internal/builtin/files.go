@@ -0,0 +1,50 @@
+package builtin
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// findFiles takes the root of a clone and globs. It returns the files, relative to the
+// root, whose path or name matches one of the globs, skipping the .git directory.
+func findFiles(dir string, globs []string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		for _, glob := range globs {
+			pathMatch, _ := filepath.Match(glob, rel)
+			nameMatch, _ := filepath.Match(glob, info.Name())
+			if pathMatch || nameMatch {
+				files = append(files, rel)
+				break
+			}
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// writeFile replaces the contents of a file, keeping its permissions.
+func writeFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
@@ -0,0 +1,146 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v35/github"
+	"golang.org/x/mod/semver"
+)
+
+// latestTags caches latest tag lookups, as every repository in a run asks for the same ones.
+var latestTags = struct {
+	sync.Mutex
+	tags map[string]string
+}{tags: make(map[string]string)}
+
+// tagCommits caches the commit each tag points at, for pinning.
+var tagCommits = struct {
+	sync.Mutex
+	shas map[string]string
+}{shas: make(map[string]string)}
+
+// latestTag takes a GitHub client and a repository. It returns the tag of the repository's
+// latest release, falling back to its highest semantic version tag when it has no releases.
+func latestTag(ctx context.Context, client *github.Client, owner, repo string) (string, error) {
+	if client == nil {
+		return "", errors.New("looking up the latest tag needs a GitHub client")
+	}
+
+	key := strings.ToLower(owner + "/" + repo)
+	latestTags.Lock()
+	tag, ok := latestTags.tags[key]
+	latestTags.Unlock()
+	if ok {
+		return tag, nil
+	}
+
+	release, resp, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+	switch {
+	case err == nil:
+		tag = release.GetTagName()
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		tag, err = highestTag(ctx, client, owner, repo)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("error getting latest release of %s/%s: %w", owner, repo, err)
+	}
+
+	latestTags.Lock()
+	latestTags.tags[key] = tag
+	latestTags.Unlock()
+	return tag, nil
+}
+
+// tagCommit takes a GitHub client, a repository and a tag. It returns the full SHA of the
+// commit the tag points at.
+func tagCommit(ctx context.Context, client *github.Client, owner, repo, tag string) (string, error) {
+	if client == nil {
+		return "", errors.New("looking up a tag's commit needs a GitHub client")
+	}
+
+	key := strings.ToLower(owner+"/"+repo) + "@" + tag
+	tagCommits.Lock()
+	sha, ok := tagCommits.shas[key]
+	tagCommits.Unlock()
+	if ok {
+		return sha, nil
+	}
+
+	sha, _, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, tag, "")
+	if err != nil {
+		return "", fmt.Errorf("error getting commit of %s/%s@%s: %w", owner, repo, tag, err)
+	}
+
+	tagCommits.Lock()
+	tagCommits.shas[key] = sha
+	tagCommits.Unlock()
+	return sha, nil
+}
+
+// highestTag returns the highest semantic version tag of a repository, ignoring pre-releases.
+func highestTag(ctx context.Context, client *github.Client, owner, repo string) (string, error) {
+	var highest string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		tags, resp, err := client.Repositories.ListTags(ctx, owner, repo, opt)
+		if err != nil {
+			return "", fmt.Errorf("error listing tags of %s/%s: %w", owner, repo, err)
+		}
+
+		for _, tag := range tags {
+			version := semverOf(tag.GetName())
+			if !semver.IsValid(version) || semver.Prerelease(version) != "" {
+				continue
+			}
+			if highest == "" || semver.Compare(version, semverOf(highest)) > 0 {
+				highest = tag.GetName()
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	if highest == "" {
+		return "", fmt.Errorf("no releases or version tags found in %s/%s", owner, repo)
+	}
+	return highest, nil
+}
+
+// tagExists takes a GitHub client, a repository and a tag, and reports whether the tag
+// resolves to a commit. The commit is cached for tagCommit.
+func tagExists(ctx context.Context, client *github.Client, owner, repo, tag string) (bool, error) {
+	if client == nil {
+		return false, errors.New("looking up a tag needs a GitHub client")
+	}
+
+	sha, resp, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, tag, "")
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting commit of %s/%s@%s: %w", owner, repo, tag, err)
+	}
+
+	tagCommits.Lock()
+	tagCommits.shas[strings.ToLower(owner+"/"+repo)+"@"+tag] = sha
+	tagCommits.Unlock()
+	return true, nil
+}
+
+// semverOf returns a tag as a semantic version with the v prefix the semver package needs.
+func semverOf(tag string) string {
+	if strings.HasPrefix(tag, "v") {
+		return tag
+	}
+	return "v" + tag
+}
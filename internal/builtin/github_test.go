@@ -0,0 +1,33 @@
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+)
+
+// TestHighestTag checks the highest release tag is picked by semantic version, ignoring
+// pre-releases and tags that aren't versions.
+func TestHighestTag(t *testing.T) {
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposTagsByOwnerByRepo,
+			[]github.RepositoryTag{
+				{Name: github.String("1.9.0")},
+				{Name: github.String("1.10.0")},
+				{Name: github.String("2.0.0-rc1")},
+				{Name: github.String("latest")},
+			},
+		),
+	))
+
+	got, err := highestTag(context.Background(), client, "ministryofjustice", "cloud-platform-terraform-irsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "1.10.0" {
+		t.Errorf("highestTag() = %q, want 1.10.0", got)
+	}
+}
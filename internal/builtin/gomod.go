@@ -0,0 +1,126 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// GoModBump bumps require directives and the go directive of every go.mod in a clone,
+// including nested modules, with golang.org/x/mod/modfile rather than the go command, so
+// no Go toolchain is needed. go.sum isn't updated. Its arguments are:
+//
+//	require comma separated module@version pairs to bump, i.e. github.com/spf13/cobra@v1.7.0.
+//	        Only modules already required are bumped, and never downgraded
+//	go      the version to bump the go directive to, i.e. 1.20. Never downgraded
+//	files   comma separated globs of the files to edit, "go.mod" by default
+func GoModBump(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	requires := make(map[string]string)
+	for _, pair := range in.Args.List("require") {
+		i := strings.LastIndex(pair, "@")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid require argument %q, want module@version", pair)
+		}
+		path, version := pair[:i], pair[i+1:]
+		if err := module.Check(path, version); err != nil {
+			return nil, fmt.Errorf("invalid require argument: %w", err)
+		}
+		requires[path] = version
+	}
+
+	goVersion := in.Args.Get("go", "")
+	if goVersion != "" && !modfile.GoVersionRE.MatchString(goVersion) {
+		return nil, fmt.Errorf("invalid go argument %q, want a version like 1.20", goVersion)
+	}
+	if len(requires) == 0 && goVersion == "" {
+		return nil, errors.New("at least one of the require and go arguments is needed")
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transform.Result{Name: "gomod-bump"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := goModBumpFile(filepath.Join(in.Dir, rel), requires, goVersion)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	var bumped []string
+	for path, version := range requires {
+		bumped = append(bumped, path+"@"+version)
+	}
+	sort.Strings(bumped)
+	if goVersion != "" {
+		bumped = append(bumped, "go "+goVersion)
+	}
+	result.Summary = fmt.Sprintf("Bumped %s in %d go.mod file(s)", strings.Join(bumped, ", "), len(result.Files))
+	return result, nil
+}
+
+// goModBumpFile bumps a single go.mod, rewriting it only if its contents changed.
+func goModBumpFile(filename string, requires map[string]string, goVersion string) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := modfile.Parse(filename, src, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Only format files that were edited, so untouched ones aren't tidied up
+	edited := false
+	for _, r := range f.Require {
+		version, ok := requires[r.Mod.Path]
+		if !ok || semver.Compare(r.Mod.Version, version) >= 0 {
+			continue
+		}
+		if err := f.AddRequire(r.Mod.Path, version); err != nil {
+			return false, err
+		}
+		edited = true
+	}
+
+	if goVersion != "" && f.Go != nil && semver.Compare("v"+f.Go.Version, "v"+goVersion) < 0 {
+		if err := f.AddGoStmt(goVersion); err != nil {
+			return false, err
+		}
+		edited = true
+	}
+
+	if !edited {
+		return false, nil
+	}
+
+	f.Cleanup()
+	out, err := f.Format()
+	if err != nil {
+		return false, err
+	}
+	if string(out) == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, out)
+}
@@ -0,0 +1,85 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestGoModBump checks requires and the go directive are bumped in nested modules,
+// keeping comments and never downgrading.
+func TestGoModBump(t *testing.T) {
+	const root = `module github.com/ministryofjustice/example
+
+go 1.17
+
+require (
+	github.com/spf13/cobra v1.6.1 // pinned for the docs generator
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/hashicorp/hcl/v2 v2.16.1 // indirect
+`
+	const nested = `module github.com/ministryofjustice/example/test
+
+go 1.21
+
+require github.com/spf13/cobra v1.8.0
+`
+
+	dir := t.TempDir()
+	files := map[string]string{"go.mod": root, filepath.Join("test", "go.mod"): nested}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := GoModBump(context.Background(), &transform.Input{Dir: dir, Args: transform.Args{
+		"require": "github.com/spf13/cobra@v1.7.0,github.com/hashicorp/hcl/v2@v2.17.0,github.com/google/uuid@v1.3.0",
+		"go":      "1.20",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0] != "go.mod" {
+		t.Errorf("GoModBump() files = %v, want [go.mod]", result.Files)
+	}
+
+	want := map[string]string{
+		"go.mod": `module github.com/ministryofjustice/example
+
+go 1.20
+
+require (
+	github.com/spf13/cobra v1.7.0 // pinned for the docs generator
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/hashicorp/hcl/v2 v2.17.0 // indirect
+`,
+		filepath.Join("test", "go.mod"): nested,
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("GoModBump() wrote %s\n%s\nwant\n%s", name, got, content)
+		}
+	}
+
+	for _, args := range []transform.Args{{}, {"require": "cobra"}, {"go": "one"}} {
+		if _, err := GoModBump(context.Background(), &transform.Input{Dir: dir, Args: args}); err == nil {
+			t.Errorf("GoModBump(%v) expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// HCLSet sets an attribute in every matching HCL file, keeping the rest of the file's
+// formatting and comments. Its arguments are:
+//
+//	path   the attribute to set, i.e. terraform.required_version. Block labels follow
+//	       the block type, i.e. module.vpc.source, and keys inside an object follow the
+//	       attribute, i.e. terraform.required_providers.aws.version
+//	value  the new value, as a string
+//	expr   the new value, as a raw HCL expression i.e. ["a", "b"], instead of value
+//	files  comma separated globs of the files to edit, "*.tf" by default
+//	create add the attribute to matching blocks that don't have it, false by default
+func HCLSet(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	path, err := in.Args.Required("path")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := hclValue(in.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	create, err := in.Args.Bool("create", false)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transform.Result{Name: "hcl-set"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := hclSetFile(filepath.Join(in.Dir, rel), strings.Split(path, "."), value, create)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	result.Summary = fmt.Sprintf("Set %s to %s in %d file(s)", path, strings.TrimSpace(string(value.Bytes())), len(result.Files))
+	return result, nil
+}
+
+// hclValue returns the tokens of the new value from either the value or expr argument.
+func hclValue(args transform.Args) (hclwrite.Tokens, error) {
+	value, hasValue := args["value"]
+	expr, hasExpr := args["expr"]
+	if hasValue == hasExpr {
+		return nil, errors.New("exactly one of the value and expr arguments is needed")
+	}
+
+	var tokens hclwrite.Tokens
+	if hasValue {
+		tokens = hclwrite.TokensForValue(cty.StringVal(value))
+	} else {
+		f, diags := hclwrite.ParseConfig([]byte("expr = "+expr+"\n"), "expr", hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid expr argument: %s", diags.Error())
+		}
+		tokens = f.Body().GetAttribute("expr").Expr().BuildTokens(nil)
+	}
+
+	if len(tokens) > 0 {
+		tokens[0].SpacesBefore = 1
+	}
+	return tokens, nil
+}
+
+// hclSetFile sets the attribute at path in a single file, rewriting it only if its
+// contents changed.
+func hclSetFile(filename string, path []string, value hclwrite.Tokens, create bool) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return false, fmt.Errorf("error parsing: %s", diags.Error())
+	}
+
+	setBodyAttribute(f.Body(), path, value, create)
+
+	out := f.Bytes()
+	if string(out) == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, out)
+}
+
+// setBodyAttribute walks path from body, descending into every block whose type and
+// leading labels match, and sets the attribute it ends at.
+func setBodyAttribute(body *hclwrite.Body, path []string, value hclwrite.Tokens, create bool) {
+	if len(path) == 0 {
+		return
+	}
+
+	if attr := body.GetAttribute(path[0]); attr != nil {
+		if len(path) == 1 {
+			body.SetAttributeRaw(path[0], value)
+			return
+		}
+		tokens, ok := setObjectKey(attr.Expr().BuildTokens(nil), path[1:], value)
+		if ok {
+			body.SetAttributeRaw(path[0], tokens)
+		}
+		return
+	}
+
+	found := false
+	for _, block := range body.Blocks() {
+		if block.Type() != path[0] {
+			continue
+		}
+
+		rest := path[1:]
+		labels := block.Labels()
+		if len(labels) >= len(rest) {
+			continue
+		}
+		matches := true
+		for i, label := range labels {
+			if rest[i] != label {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		found = true
+		setBodyAttribute(block.Body(), rest[len(labels):], value, create)
+	}
+
+	if !found && create && len(path) == 1 {
+		body.SetAttributeRaw(path[0], value)
+	}
+}
+
+// setObjectKey takes the tokens of an object expression, i.e. { source = "x", version = "1" },
+// and the keys leading to a value inside it. It returns the tokens with that value replaced,
+// and false if the keys weren't found.
+func setObjectKey(tokens hclwrite.Tokens, keys []string, value hclwrite.Tokens) (hclwrite.Tokens, bool) {
+	start, end, ok := objectValue(tokens, keys)
+	if !ok {
+		return nil, false
+	}
+
+	out := make(hclwrite.Tokens, 0, len(tokens)-(end-start)+len(value))
+	out = append(out, tokens[:start]...)
+	out = append(out, value...)
+	out = append(out, tokens[end:]...)
+	return out, true
+}
+
+// objectValue takes the tokens of an object expression and the keys leading to a value
+// inside it. It returns where the value's tokens start and end, and false if the keys
+// weren't found.
+func objectValue(tokens hclwrite.Tokens, keys []string) (int, int, bool) {
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenOBrace {
+		return 0, 0, false
+	}
+
+	for i := 1; i < len(tokens); {
+		// Skip to the start of the next item
+		t := tokens[i]
+		if t.Type == hclsyntax.TokenNewline || t.Type == hclsyntax.TokenComma || t.Type == hclsyntax.TokenComment {
+			i++
+			continue
+		}
+		if t.Type == hclsyntax.TokenCBrace {
+			break
+		}
+
+		key, next := objectKey(tokens, i)
+		if next >= len(tokens) || (tokens[next].Type != hclsyntax.TokenEqual && tokens[next].Type != hclsyntax.TokenColon) {
+			return 0, 0, false
+		}
+		start := next + 1
+		end := valueEnd(tokens, start)
+
+		if key == keys[0] {
+			if len(keys) == 1 {
+				return start, end, true
+			}
+			nestedStart, nestedEnd, ok := objectValue(tokens[start:end], keys[1:])
+			return start + nestedStart, start + nestedEnd, ok
+		}
+		i = end
+	}
+
+	return 0, 0, false
+}
+
+// quotedString returns the value of tokens that are a plain quoted string, i.e. "~> 3.0",
+// and false if they're anything else.
+func quotedString(tokens hclwrite.Tokens) (string, bool) {
+	if len(tokens) == 2 && tokens[0].Type == hclsyntax.TokenOQuote && tokens[1].Type == hclsyntax.TokenCQuote {
+		return "", true
+	}
+	if len(tokens) != 3 || tokens[0].Type != hclsyntax.TokenOQuote || tokens[1].Type != hclsyntax.TokenQuotedLit || tokens[2].Type != hclsyntax.TokenCQuote {
+		return "", false
+	}
+	return string(tokens[1].Bytes), true
+}
+
+// stringTokens returns the tokens of a quoted string value, spaced to follow an equals sign.
+func stringTokens(value string) hclwrite.Tokens {
+	tokens := hclwrite.TokensForValue(cty.StringVal(value))
+	tokens[0].SpacesBefore = 1
+	return tokens
+}
+
+// objectKey returns the name of the object key starting at tokens[i], either an identifier
+// or a quoted string, and the index of the token following it.
+func objectKey(tokens hclwrite.Tokens, i int) (string, int) {
+	if tokens[i].Type == hclsyntax.TokenOQuote {
+		var key strings.Builder
+		j := i + 1
+		for ; j < len(tokens) && tokens[j].Type != hclsyntax.TokenCQuote; j++ {
+			key.Write(tokens[j].Bytes)
+		}
+		return key.String(), j + 1
+	}
+	return string(tokens[i].Bytes), i + 1
+}
+
+// valueEnd returns the index just past the object value starting at tokens[start], which
+// runs until a newline, comma, comment or the closing brace outside of any brackets.
+func valueEnd(tokens hclwrite.Tokens, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].Type {
+		case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+			hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl, hclsyntax.TokenOHeredoc:
+			depth++
+		case hclsyntax.TokenCBrack, hclsyntax.TokenCParen, hclsyntax.TokenTemplateSeqEnd, hclsyntax.TokenCHeredoc:
+			depth--
+		case hclsyntax.TokenCBrace:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case hclsyntax.TokenNewline, hclsyntax.TokenComma, hclsyntax.TokenComment:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// HCLValues takes the contents of an HCL file and a path, as given to hcl-set, and returns
+// the value of every attribute at that path. Quoted strings are returned unquoted, other
+// expressions as they're written.
+func HCLValues(src []byte, filename, path string) ([]string, error) {
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error parsing: %s", diags.Error())
+	}
+
+	var values []string
+	for _, tokens := range bodyValues(f.Body(), strings.Split(path, ".")) {
+		if s, ok := quotedString(tokens); ok {
+			values = append(values, s)
+			continue
+		}
+		values = append(values, strings.TrimSpace(string(tokens.Bytes())))
+	}
+	return values, nil
+}
+
+// bodyValues walks path from body like setBodyAttribute, returning the tokens of every
+// value it ends at.
+func bodyValues(body *hclwrite.Body, path []string) []hclwrite.Tokens {
+	if len(path) == 0 {
+		return nil
+	}
+
+	if attr := body.GetAttribute(path[0]); attr != nil {
+		tokens := attr.Expr().BuildTokens(nil)
+		if len(path) == 1 {
+			return []hclwrite.Tokens{tokens}
+		}
+		start, end, ok := objectValue(tokens, path[1:])
+		if !ok {
+			return nil
+		}
+		return []hclwrite.Tokens{tokens[start:end]}
+	}
+
+	var values []hclwrite.Tokens
+	for _, block := range body.Blocks() {
+		rest := path[1:]
+		labels := block.Labels()
+		if block.Type() != path[0] || len(labels) >= len(rest) {
+			continue
+		}
+		matches := true
+		for i, label := range labels {
+			matches = matches && rest[i] == label
+		}
+		if matches {
+			values = append(values, bodyValues(block.Body(), rest[len(labels):])...)
+		}
+	}
+	return values
+}
@@ -0,0 +1,201 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestHCLSet checks attributes are set at each kind of path without disturbing the rest
+// of the file, and that unchanged files aren't reported.
+func TestHCLSet(t *testing.T) {
+	const versions = `# Pinned versions
+terraform {
+  required_version = ">= 0.14" # keep in step with CI
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+  }
+}
+
+module "vpc" {
+  source = "github.com/ministryofjustice/vpc?ref=1.0.0"
+}
+`
+
+	tests := []struct {
+		name      string
+		args      transform.Args
+		want      string
+		wantFiles int
+		wantErr   bool
+	}{
+		{
+			name: "top level block attribute",
+			args: transform.Args{"path": "terraform.required_version", "value": ">= 1.2.5"},
+			want: `# Pinned versions
+terraform {
+  required_version = ">= 1.2.5" # keep in step with CI
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+  }
+}
+
+module "vpc" {
+  source = "github.com/ministryofjustice/vpc?ref=1.0.0"
+}
+`,
+			wantFiles: 1,
+		},
+		{
+			name: "object key",
+			args: transform.Args{"path": "terraform.required_providers.aws.version", "value": "~> 4.0"},
+			want: `# Pinned versions
+terraform {
+  required_version = ">= 0.14" # keep in step with CI
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+  }
+}
+
+module "vpc" {
+  source = "github.com/ministryofjustice/vpc?ref=1.0.0"
+}
+`,
+			wantFiles: 1,
+		},
+		{
+			name: "labelled block with expr",
+			args: transform.Args{"path": "module.vpc.source", "expr": `"github.com/ministryofjustice/vpc?ref=2.0.0"`},
+			want: `# Pinned versions
+terraform {
+  required_version = ">= 0.14" # keep in step with CI
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+  }
+}
+
+module "vpc" {
+  source = "github.com/ministryofjustice/vpc?ref=2.0.0"
+}
+`,
+			wantFiles: 1,
+		},
+		{
+			name:      "missing attribute without create",
+			args:      transform.Args{"path": "module.vpc.version", "value": "1.0.0"},
+			want:      versions,
+			wantFiles: 0,
+		},
+		{
+			name: "missing attribute with create",
+			args: transform.Args{"path": "module.vpc.version", "value": "1.0.0", "create": "true"},
+			want: `# Pinned versions
+terraform {
+  required_version = ">= 0.14" # keep in step with CI
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+  }
+}
+
+module "vpc" {
+  source  = "github.com/ministryofjustice/vpc?ref=1.0.0"
+  version = "1.0.0"
+}
+`,
+			wantFiles: 1,
+		},
+		{name: "value and expr", args: transform.Args{"path": "terraform.required_version", "value": "1", "expr": "1"}, wantErr: true},
+		{name: "no path", args: transform.Args{"value": "1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "versions.tf")
+			if err := os.WriteFile(path, []byte(versions), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := HCLSet(context.Background(), &transform.Input{Dir: dir, Args: tt.args})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HCLSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(result.Files) != tt.wantFiles {
+				t.Errorf("HCLSet() files = %v, want %d", result.Files, tt.wantFiles)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("HCLSet() wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHCLValues checks values are read at the same paths hcl-set writes to.
+func TestHCLValues(t *testing.T) {
+	src := []byte(`terraform {
+  required_version = ">= 1.2.5"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+  }
+}
+
+module "vpc" {
+  source = "github.com/ministryofjustice/vpc?ref=1.0.0"
+  azs    = ["a", "b"]
+}
+`)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"terraform.required_version", []string{">= 1.2.5"}},
+		{"terraform.required_providers.aws.version", []string{"~> 4.0"}},
+		{"module.vpc.azs", []string{`["a", "b"]`}},
+		{"module.other.source", nil},
+	}
+	for _, tt := range tests {
+		got, err := HCLValues(src, "main.tf", tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("HCLValues(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// Replace finds and replaces text in every matching file, the same on every platform
+// unlike sed. The number of matches replaced in each file is recorded in the result. Its
+// arguments are:
+//
+//	files       comma separated globs of the files to edit, i.e. *.tf,*.md
+//	pattern     a regular expression to find, with RE2 syntax
+//	literal     the exact text to find, instead of pattern
+//	replacement the text to replace matches with. With pattern, $1 or ${name} expand to
+//	            capture groups
+//	max-count   the most matches to replace in each file, every match by default
+func Replace(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	globs := in.Args.List("files")
+	if len(globs) == 0 {
+		return nil, errors.New(`missing required argument "files"`)
+	}
+
+	pattern, hasPattern := in.Args["pattern"]
+	literal, hasLiteral := in.Args["literal"]
+	if hasPattern == hasLiteral {
+		return nil, errors.New("exactly one of the pattern and literal arguments is needed")
+	}
+	if hasLiteral {
+		if literal == "" {
+			return nil, errors.New("literal can't be empty")
+		}
+		pattern = regexp.QuoteMeta(literal)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	replacement, ok := in.Args["replacement"]
+	if !ok {
+		return nil, errors.New(`missing required argument "replacement"`)
+	}
+
+	maxCount := -1
+	if value := in.Args.Get("max-count", ""); value != "" {
+		maxCount, err = strconv.Atoi(value)
+		if err != nil || maxCount < 1 {
+			return nil, fmt.Errorf("invalid max-count %q, want a positive number", value)
+		}
+	}
+
+	files, err := findFiles(in.Dir, globs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transform.Result{Name: "replace", Matches: make(map[string]int)}
+	total := 0
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		matches, changed, err := replaceFile(filepath.Join(in.Dir, rel), re, replacement, !hasLiteral, maxCount)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if matches > 0 {
+			result.Matches[rel] = matches
+			total += matches
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	find := in.Args.Get("pattern", literal)
+	result.Summary = fmt.Sprintf("Replaced %d match(es) of %q in %d file(s)", total, find, len(result.Files))
+	return result, nil
+}
+
+// replaceFile replaces up to maxCount matches of re in a single file, expanding capture
+// groups in the replacement if expand is set. It returns how many matches were replaced
+// and whether the file changed. Binary files are skipped.
+func replaceFile(filename string, re *regexp.Regexp, replacement string, expand bool, maxCount int) (int, bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, false, err
+	}
+	if isBinary(src) {
+		return 0, false, nil
+	}
+
+	matches := re.FindAllSubmatchIndex(src, maxCount)
+	if len(matches) == 0 {
+		return 0, false, nil
+	}
+
+	var out []byte
+	last := 0
+	for _, match := range matches {
+		out = append(out, src[last:match[0]]...)
+		if expand {
+			out = re.Expand(out, []byte(replacement), src, match)
+		} else {
+			out = append(out, replacement...)
+		}
+		last = match[1]
+	}
+	out = append(out, src[last:]...)
+
+	if bytes.Equal(out, src) {
+		return len(matches), false, nil
+	}
+	return len(matches), true, writeFile(filename, out)
+}
+
+// isBinary reports whether a file looks binary, by a NUL byte near its start.
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
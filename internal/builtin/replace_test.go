@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestReplace checks regex and literal replacements, capture groups and max-count, and
+// that matches are counted per file.
+func TestReplace(t *testing.T) {
+	files := map[string]string{
+		"main.tf":        "source = \"git::https://github.com/org/mod.git?ref=1.0.0\"\nsource = \"git::https://github.com/org/mod.git?ref=1.0.0\"\n",
+		"docs/README.md": "Uses mod at ref=1.0.0 ($1).\n",
+		"logo.png":       "ref=1.0.0\x00",
+	}
+
+	tests := []struct {
+		name        string
+		args        transform.Args
+		want        map[string]string
+		wantMatches map[string]int
+		wantErr     bool
+	}{
+		{
+			name: "regex with capture group",
+			args: transform.Args{"files": "*.tf", "pattern": `mod\.git\?ref=(\d+)\.\d+\.\d+`, "replacement": "mod.git?ref=${1}.1.0"},
+			want: map[string]string{
+				"main.tf": "source = \"git::https://github.com/org/mod.git?ref=1.1.0\"\nsource = \"git::https://github.com/org/mod.git?ref=1.1.0\"\n",
+			},
+			wantMatches: map[string]int{"main.tf": 2},
+		},
+		{
+			name: "literal doesn't expand",
+			args: transform.Args{"files": "*.tf,*.md,*.png", "literal": "ref=1.0.0", "replacement": "ref=$1", "max-count": "1"},
+			want: map[string]string{
+				"main.tf":        "source = \"git::https://github.com/org/mod.git?ref=$1\"\nsource = \"git::https://github.com/org/mod.git?ref=1.0.0\"\n",
+				"docs/README.md": "Uses mod at ref=$1 ($1).\n",
+			},
+			wantMatches: map[string]int{"main.tf": 1, "docs/README.md": 1},
+		},
+		{name: "pattern and literal", args: transform.Args{"files": "*", "pattern": "a", "literal": "a", "replacement": ""}, wantErr: true},
+		{name: "no replacement", args: transform.Args{"files": "*", "pattern": "a"}, wantErr: true},
+		{name: "invalid pattern", args: transform.Args{"files": "*", "pattern": "(", "replacement": ""}, wantErr: true},
+		{name: "invalid max-count", args: transform.Args{"files": "*", "pattern": "a", "replacement": "", "max-count": "0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := Replace(context.Background(), &transform.Input{Dir: dir, Args: tt.args})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Replace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(result.Matches, tt.wantMatches) {
+				t.Errorf("Replace() matches = %v, want %v", result.Matches, tt.wantMatches)
+			}
+			for name, content := range files {
+				if want, ok := tt.want[name]; ok {
+					content = want
+				}
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != content {
+					t.Errorf("Replace() wrote %s as %q, want %q", name, got, content)
+				}
+			}
+		})
+	}
+}
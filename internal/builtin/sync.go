@@ -0,0 +1,181 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/render"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// Sync makes files in the clone match a local template directory, creating and updating
+// them, i.e. to keep CODEOWNERS, workflows and licences the same across repositories.
+// Files ending .tmpl are rendered as Go templates per repository, i.e. {{.Repo.Name}},
+// and written without the suffix; others are copied as they are so ${{ }} in workflows
+// is left alone. A template that renders empty deletes its file. In a dry run nothing is
+// changed and the drift is reported instead. Its arguments are:
+//
+//	source the local template directory, laid out as the files should be in the clone
+//	delete comma separated globs of files to delete from the clone, optional
+func Sync(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	source, err := in.Args.Required("source")
+	if err != nil {
+		return nil, err
+	}
+	source, err = filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted, err := syncFiles(source, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if globs := in.Args.List("delete"); len(globs) > 0 {
+		files, err := findFiles(in.Dir, globs)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range files {
+			if _, ok := wanted[rel]; !ok {
+				wanted[rel] = syncFile{delete: true}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(wanted))
+	for rel := range wanted {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	result := &transform.Result{Name: "sync"}
+	var drift []string
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		action, err := wanted[rel].apply(filepath.Join(in.Dir, rel), in.DryRun)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if action != "" {
+			result.Files = append(result.Files, rel)
+			drift = append(drift, action+" "+rel)
+		}
+	}
+
+	result.Summary = fmt.Sprintf("Synced %d file(s) from %s", len(result.Files), filepath.Base(source))
+	if in.DryRun {
+		result.Summary = fmt.Sprintf("%d file(s) drifted from %s", len(result.Files), filepath.Base(source))
+	}
+	if len(drift) > 0 {
+		result.Summary += ":\n" + strings.Join(drift, "\n")
+	}
+	return result, nil
+}
+
+// syncFile is what a file in the clone should be.
+type syncFile struct {
+	content []byte
+	mode    fs.FileMode
+	delete  bool
+}
+
+// syncFiles reads the template directory, rendering .tmpl files for the repository, and
+// returns what each file in the clone should be keyed by its path relative to the root.
+func syncFiles(source string, in *transform.Input) (map[string]syncFile, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source %s isn't a directory", source)
+	}
+
+	wanted := make(map[string]syncFile)
+	err = filepath.Walk(source, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		file := syncFile{content: data, mode: info.Mode().Perm()}
+		if strings.HasSuffix(rel, ".tmpl") {
+			rel = strings.TrimSuffix(rel, ".tmpl")
+			rendered, err := render.Render(rel, string(data), render.RepoData{Repo: in.Repo})
+			if err != nil {
+				return err
+			}
+			file.content = []byte(rendered)
+			file.delete = strings.TrimSpace(rendered) == ""
+		}
+
+		wanted[rel] = file
+		return nil
+	})
+	return wanted, err
+}
+
+// apply makes the file at path match, unless dryRun is set. It returns what it did, or
+// would do: create, update or delete, or nothing if the file already matches.
+func (f syncFile) apply(path string, dryRun bool) (string, error) {
+	current, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	var action string
+	switch {
+	case f.delete && !exists:
+		return "", nil
+	case f.delete:
+		action = "delete"
+	case !exists:
+		action = "create"
+	case string(current) != string(f.content):
+		action = "update"
+	default:
+		return "", nil
+	}
+
+	if dryRun {
+		return action, nil
+	}
+
+	switch action {
+	case "delete":
+		return action, os.Remove(path)
+	case "create":
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		return action, os.WriteFile(path, f.content, f.mode)
+	default:
+		return action, writeFile(path, f.content)
+	}
+}
@@ -0,0 +1,118 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestSync checks files are created, updated and deleted to match the templates, with
+// .tmpl files rendered per repository, and that a dry run only reports the drift.
+func TestSync(t *testing.T) {
+	source := t.TempDir()
+	templates := map[string]string{
+		".github/CODEOWNERS.tmpl":            "* @ministryofjustice/{{.Repo.Name}}-owners\n",
+		".github/workflows/format-code.yml":  "run: echo ${{ github.sha }}\n",
+		"LICENSE":                            "MIT\n",
+		".github/pull_request_template.tmpl": "{{if eq .Repo.GetName \"other\"}}Describe the change{{end}}\n",
+	}
+	writeFiles(t, source, templates)
+
+	clone := map[string]string{
+		"LICENSE":                           "Apache\n",
+		".github/pull_request_template":     "Old template\n",
+		".github/workflows/old.yml":         "on: push\n",
+		".github/workflows/format-code.yml": "run: echo ${{ github.sha }}\n",
+	}
+
+	tests := []struct {
+		name      string
+		dryRun    bool
+		want      map[string]string
+		wantDrift []string
+	}{
+		{
+			name: "sync",
+			want: map[string]string{
+				".github/CODEOWNERS":                "* @ministryofjustice/example-owners\n",
+				".github/workflows/format-code.yml": "run: echo ${{ github.sha }}\n",
+				"LICENSE":                           "MIT\n",
+			},
+			wantDrift: []string{"delete .github/pull_request_template", "create .github/CODEOWNERS", "update LICENSE", "delete .github/workflows/old.yml"},
+		},
+		{
+			name:      "dry run",
+			dryRun:    true,
+			want:      clone,
+			wantDrift: []string{"delete .github/pull_request_template", "create .github/CODEOWNERS", "update LICENSE", "delete .github/workflows/old.yml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, clone)
+
+			result, err := Sync(context.Background(), &transform.Input{
+				Dir:    dir,
+				Repo:   &github.Repository{Name: github.String("example")},
+				Args:   transform.Args{"source": source, "delete": ".github/workflows/old.yml"},
+				DryRun: tt.dryRun,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, drift := range tt.wantDrift {
+				if !strings.Contains(result.Summary, drift) {
+					t.Errorf("Sync() summary %q is missing %q", result.Summary, drift)
+				}
+			}
+			if len(result.Files) != len(tt.wantDrift) {
+				t.Errorf("Sync() files = %v, want %d", result.Files, len(tt.wantDrift))
+			}
+
+			var got []string
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(dir, path)
+				got = append(got, rel)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if want, ok := tt.want[rel]; !ok || string(data) != want {
+					t.Errorf("Sync() left %s as %q, want %q", rel, data, want)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Sync() left files %v, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+// writeFiles writes files, keyed by their path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+var (
+	// constraintOperator matches the operator a version constraint starts with, i.e. "~> ".
+	constraintOperator = regexp.MustCompile(`^\s*(~>|>=|<=|!=|=|>|<)?\s*`)
+	// githubSource matches the owner and name of a GitHub module source or action.
+	githubSource = regexp.MustCompile(`github\.com[/:]([^/]+)/([^/?]+?)(\.git)?(//|\?|$)`)
+	// refParam matches the ref query parameter of a git module source.
+	refParam = regexp.MustCompile(`([?&])ref=[^&]*`)
+)
+
+// TerraformBump bumps a provider's version constraint or a module's version in every
+// matching Terraform file, keeping the rest of the file's formatting and comments. Its
+// arguments are:
+//
+//	provider the local name of a provider in required_providers to bump, i.e. aws
+//	module   part of the source of the modules to bump, i.e. cloud-platform-terraform-s3-bucket.
+//	         Git sources have their ?ref= set, registry modules their version attribute
+//	version  the version to bump to, or "latest" for the latest release on GitHub. With
+//	         latest a provider keeps the operator of its existing constraint, i.e. "~> "
+//	files    comma separated globs of the files to edit, "*.tf" by default
+func TerraformBump(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	provider, module := in.Args.Get("provider", ""), in.Args.Get("module", "")
+	if (provider == "") == (module == "") {
+		return nil, errors.New("exactly one of the provider and module arguments is needed")
+	}
+
+	version, err := in.Args.Required("version")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	bump := &terraformBump{ctx: ctx, client: in.Client, version: version}
+	result := &transform.Result{Name: "terraform-bump"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := bump.file(filepath.Join(in.Dir, rel), provider, module)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	name := module
+	if provider != "" {
+		name = provider + " provider"
+	}
+	result.Summary = fmt.Sprintf("Bumped %s to %s in %d file(s)", name, strings.Join(bump.resolved, ", "), len(result.Files))
+	if len(bump.resolved) == 0 {
+		result.Summary = fmt.Sprintf("Bumped %s to %s in %d file(s)", name, version, len(result.Files))
+	}
+	return result, nil
+}
+
+// terraformBump holds the target version of a bump, resolving latest once per GitHub
+// repository it's asked about.
+type terraformBump struct {
+	ctx      context.Context
+	client   *github.Client
+	version  string
+	resolved []string
+}
+
+// target returns the version to bump to, looking up the latest tag of owner/repo if asked to.
+func (b *terraformBump) target(owner, repo string) (string, error) {
+	if b.version != "latest" {
+		return b.version, nil
+	}
+
+	tag, err := latestTag(b.ctx, b.client, owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	found := false
+	for _, r := range b.resolved {
+		found = found || r == tag
+	}
+	if !found {
+		b.resolved = append(b.resolved, tag)
+	}
+	return tag, nil
+}
+
+// file bumps the provider or module in a single file, rewriting it only if its contents
+// changed.
+func (b *terraformBump) file(filename, provider, module string) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return false, fmt.Errorf("error parsing: %s", diags.Error())
+	}
+
+	for _, block := range f.Body().Blocks() {
+		switch {
+		case provider != "" && block.Type() == "terraform":
+			for _, providers := range block.Body().Blocks() {
+				if providers.Type() != "required_providers" {
+					continue
+				}
+				err = b.provider(providers.Body(), provider)
+				if err != nil {
+					return false, err
+				}
+			}
+		case module != "" && block.Type() == "module":
+			err = b.module(block.Body(), module)
+			if err != nil {
+				return false, fmt.Errorf("module %s: %w", strings.Join(block.Labels(), "."), err)
+			}
+		}
+	}
+
+	out := f.Bytes()
+	if string(out) == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, out)
+}
+
+// provider bumps the version constraint of a provider in a required_providers block, in
+// either the object form, aws = { version = "~> 3.0" }, or the older string form.
+func (b *terraformBump) provider(body *hclwrite.Body, name string) error {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return nil
+	}
+	tokens := attr.Expr().BuildTokens(nil)
+
+	// Registry providers are released from <namespace>/terraform-provider-<name> on GitHub
+	source := "hashicorp/" + name
+	if start, end, ok := objectValue(tokens, []string{"source"}); ok {
+		if s, ok := quotedString(tokens[start:end]); ok {
+			source = s
+		}
+	}
+
+	current, isObject := "", false
+	if start, end, ok := objectValue(tokens, []string{"version"}); ok {
+		current, ok = quotedString(tokens[start:end])
+		if !ok {
+			return fmt.Errorf("provider %s: version isn't a plain string", name)
+		}
+		isObject = true
+	} else if s, ok := quotedString(tokens); ok {
+		current = s
+	} else {
+		return nil
+	}
+
+	constraint := b.version
+	if b.version == "latest" {
+		owner, repo, err := providerRepo(source)
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+		tag, err := b.target(owner, repo)
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+		constraint = constraintOperator.FindString(current) + strings.TrimPrefix(tag, "v")
+	}
+
+	if isObject {
+		tokens, _ = setObjectKey(tokens, []string{"version"}, stringTokens(constraint))
+		body.SetAttributeRaw(name, tokens)
+	} else {
+		body.SetAttributeRaw(name, stringTokens(constraint))
+	}
+	return nil
+}
+
+// providerRepo takes a provider source, i.e. hashicorp/aws, and returns the GitHub owner and
+// repository its releases are published from. A source without a namespace is a hashicorp
+// provider, as it is to terraform, and a leading registry hostname is ignored.
+func providerRepo(source string) (string, string, error) {
+	parts := strings.Split(source, "/")
+	if len(parts) == 1 {
+		parts = []string{"hashicorp", parts[0]}
+	}
+	if len(parts) == 3 {
+		parts = parts[1:]
+	}
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid provider source %q", source)
+	}
+	return parts[0], "terraform-provider-" + parts[1], nil
+}
+
+// module bumps a module block whose source contains match, setting the ref of a git
+// source or the version of a registry module.
+func (b *terraformBump) module(body *hclwrite.Body, match string) error {
+	attr := body.GetAttribute("source")
+	if attr == nil {
+		return nil
+	}
+	source, ok := quotedString(attr.Expr().BuildTokens(nil))
+	if !ok || !strings.Contains(source, match) {
+		return nil
+	}
+
+	if !isGitSource(source) {
+		if b.version == "latest" {
+			return errors.New("latest is only supported for modules sourced from GitHub")
+		}
+		if body.GetAttribute("version") != nil {
+			body.SetAttributeRaw("version", stringTokens(b.version))
+		}
+		return nil
+	}
+
+	owner, repo := "", ""
+	if m := githubSource.FindStringSubmatch(source); m != nil {
+		owner, repo = m[1], m[2]
+	} else if b.version == "latest" {
+		return errors.New("latest is only supported for modules sourced from GitHub")
+	}
+
+	ref, err := b.target(owner, repo)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case refParam.MatchString(source):
+		source = refParam.ReplaceAllString(source, "${1}ref="+ref)
+	case strings.Contains(source, "?"):
+		source += "&ref=" + ref
+	default:
+		source += "?ref=" + ref
+	}
+	body.SetAttributeRaw("source", stringTokens(source))
+	return nil
+}
+
+// isGitSource reports whether a module source is a git repository rather than the registry.
+func isGitSource(source string) bool {
+	return strings.HasPrefix(source, "git::") || strings.HasPrefix(source, "git@") ||
+		strings.HasPrefix(source, "github.com/") || strings.Contains(source, "?ref=")
+}
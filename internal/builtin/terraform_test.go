@@ -0,0 +1,170 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestTerraformBump checks provider constraints and module versions are bumped to a
+// given version or the latest release, leaving everything else alone.
+func TestTerraformBump(t *testing.T) {
+	const main = `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0" # major bumps need a plan review
+    }
+    random = ">= 2.0"
+    null = {
+      source  = "null"
+      version = "3.1.0"
+    }
+  }
+}
+
+module "bucket" {
+  source = "github.com/ministryofjustice/cloud-platform-terraform-s3-bucket?ref=4.0.0"
+}
+
+module "irsa" {
+  source = "git::https://github.com/ministryofjustice/cloud-platform-terraform-irsa.git//modules/role?depth=1&ref=1.0.0"
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "3.0.0"
+}
+`
+
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			github.RepositoryRelease{TagName: github.String("v4.67.0")},
+			github.RepositoryRelease{TagName: github.String("v3.2.1")},
+			github.RepositoryRelease{TagName: github.String("v4.67.0")},
+		),
+	))
+
+	tests := []struct {
+		name    string
+		args    transform.Args
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "provider object",
+			args: transform.Args{"provider": "aws", "version": "~> 4.0"},
+			want: map[string]string{`version = "~> 3.0" # major`: `version = "~> 4.0" # major`},
+		},
+		{
+			name: "provider latest keeps operator",
+			args: transform.Args{"provider": "aws", "version": "latest"},
+			want: map[string]string{`version = "~> 3.0" # major`: `version = "~> 4.67.0" # major`},
+		},
+		{
+			name: "provider latest without namespace",
+			args: transform.Args{"provider": "null", "version": "latest"},
+			want: map[string]string{`version = "3.1.0"`: `version = "3.2.1"`},
+		},
+		{
+			name: "provider string",
+			args: transform.Args{"provider": "random", "version": ">= 3.0"},
+			want: map[string]string{`random = ">= 2.0"`: `random = ">= 3.0"`},
+		},
+		{
+			name: "git module ref",
+			args: transform.Args{"module": "cloud-platform-terraform-irsa", "version": "2.0.0"},
+			want: map[string]string{"depth=1&ref=1.0.0": "depth=1&ref=2.0.0"},
+		},
+		{
+			name: "git module latest",
+			args: transform.Args{"module": "cloud-platform-terraform-s3-bucket", "version": "latest"},
+			want: map[string]string{"s3-bucket?ref=4.0.0": "s3-bucket?ref=v4.67.0"},
+		},
+		{
+			name: "registry module",
+			args: transform.Args{"module": "terraform-aws-modules/vpc", "version": "5.0.0"},
+			want: map[string]string{`version = "3.0.0"`: `version = "5.0.0"`},
+		},
+		{name: "registry module latest", args: transform.Args{"module": "terraform-aws-modules/vpc", "version": "latest"}, wantErr: true},
+		{name: "provider and module", args: transform.Args{"provider": "aws", "module": "vpc", "version": "1"}, wantErr: true},
+		{name: "no version", args: transform.Args{"provider": "aws"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "main.tf")
+			if err := os.WriteFile(path, []byte(main), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := TerraformBump(context.Background(), &transform.Input{Dir: dir, Client: client, Args: tt.args})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TerraformBump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := main
+			for old, new := range tt.want {
+				want = replaceOnce(t, want, old, new)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("TerraformBump() wrote\n%s\nwant\n%s", got, want)
+			}
+			if len(result.Files) != 1 {
+				t.Errorf("TerraformBump() files = %v, want [main.tf]", result.Files)
+			}
+		})
+	}
+}
+
+// TestProviderRepo checks provider sources map to the GitHub repository releasing them.
+func TestProviderRepo(t *testing.T) {
+	tests := []struct {
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{source: "hashicorp/aws", want: "hashicorp/terraform-provider-aws"},
+		{source: "aws", want: "hashicorp/terraform-provider-aws"},
+		{source: "registry.terraform.io/integrations/github", want: "integrations/terraform-provider-github"},
+		{source: "", wantErr: true},
+		{source: "hashicorp/", wantErr: true},
+		{source: "a/b/c/d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			owner, repo, err := providerRepo(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("providerRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := owner + "/" + repo; !tt.wantErr && got != tt.want {
+				t.Errorf("providerRepo() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// replaceOnce replaces old in s, failing the test if it isn't there exactly once.
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	if n := strings.Count(s, old); n != 1 {
+		t.Fatalf("%q appears %d times, want 1", old, n)
+	}
+	return strings.Replace(s, old, new, 1)
+}
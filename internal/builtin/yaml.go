@@ -0,0 +1,402 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// YAMLSet sets a value in every matching YAML file. The files are only read into yaml.v3
+// node trees to find the values, which are then replaced in place, so comments, ordering,
+// indentation and unknown keys are left exactly as they were. Its arguments are:
+//
+//	path   the value to set, as dot separated keys. * matches every key or item, [n] picks
+//	       the nth item of a list and [key=value] picks the items of a list with that key,
+//	       i.e. jobs.*.steps[name=Setup Terraform].with.terraform_version
+//	value  the new value. Quoting of the existing value is kept
+//	files  comma separated globs of the files to edit, "*.yml,*.yaml" by default
+func YAMLSet(ctx context.Context, in *transform.Input) (*transform.Result, error) {
+	path, err := in.Args.Required("path")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors, err := parseYAMLPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := in.Args["value"]
+	if !ok {
+		return nil, errors.New(`missing required argument "value"`)
+	}
+
+	files, err := findFiles(in.Dir, in.Args.List("files", "*.yml", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transform.Result{Name: "yaml-set"}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		changed, err := yamlSetFile(filepath.Join(in.Dir, rel), selectors, value)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			result.Files = append(result.Files, rel)
+		}
+	}
+
+	result.Summary = fmt.Sprintf("Set %s to %q in %d file(s)", path, value, len(result.Files))
+	return result, nil
+}
+
+// yamlSelector is one step of a YAML path.
+type yamlSelector struct {
+	// key matches a mapping key, or every key or item when it's *
+	key string
+	// index picks an item of a list when hasIndex is set
+	index    int
+	hasIndex bool
+	// field and value pick the items of a list that are mappings with field set to value
+	field, value string
+}
+
+// parseYAMLPath splits a path like jobs.*.steps[name=Setup Terraform].with.version into
+// selectors. Dots inside brackets don't separate keys.
+func parseYAMLPath(path string) ([]yamlSelector, error) {
+	var selectors []yamlSelector
+	for len(path) > 0 {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+		case path[0] == '[':
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path: unclosed [ in %q", path)
+			}
+			inner := path[1:end]
+			path = path[end+1:]
+
+			if i := strings.Index(inner, "="); i > 0 {
+				selectors = append(selectors, yamlSelector{field: inner[:i], value: inner[i+1:]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path: [%s] should be an index or key=value", inner)
+			}
+			selectors = append(selectors, yamlSelector{index: index, hasIndex: true})
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			selectors = append(selectors, yamlSelector{key: path[:end]})
+			path = path[end:]
+		}
+	}
+
+	if len(selectors) == 0 {
+		return nil, errors.New("invalid path: no keys")
+	}
+	return selectors, nil
+}
+
+// yamlSetFile sets the value at every match of the selectors in a single file, rewriting
+// it only if its contents changed.
+func yamlSetFile(filename string, selectors []yamlSelector, value string) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	var matches []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("error parsing: %w", err)
+		}
+		matches = append(matches, matchYAML(&doc, selectors)...)
+	}
+
+	// Replace from the end of the file so earlier positions stay valid
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Line != matches[j].Line {
+			return matches[i].Line > matches[j].Line
+		}
+		return matches[i].Column > matches[j].Column
+	})
+
+	lines := strings.SplitAfter(string(src), "\n")
+	for _, node := range matches {
+		if node.Kind != yaml.ScalarNode {
+			return false, fmt.Errorf("line %d: can only set scalar values", node.Line)
+		}
+		if node.Value == value {
+			continue
+		}
+
+		line, err := replaceScalar(lines[node.Line-1], node, value, "")
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		lines[node.Line-1] = line
+	}
+
+	out := strings.Join(lines, "")
+	if out == string(src) {
+		return false, nil
+	}
+	return true, writeFile(filename, []byte(out))
+}
+
+// matchYAML returns the nodes below node the selectors lead to.
+func matchYAML(node *yaml.Node, selectors []yamlSelector) []*yaml.Node {
+	for node.Kind == yaml.DocumentNode || node.Kind == yaml.AliasNode {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		} else if len(node.Content) > 0 {
+			node = node.Content[0]
+		} else {
+			return nil
+		}
+	}
+	if len(selectors) == 0 {
+		return []*yaml.Node{node}
+	}
+
+	sel, rest := selectors[0], selectors[1:]
+	var children []*yaml.Node
+	switch node.Kind {
+	case yaml.MappingNode:
+		if sel.hasIndex || sel.field != "" {
+			break
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if sel.key == "*" || node.Content[i].Value == sel.key {
+				children = append(children, node.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			switch {
+			case sel.key == "*",
+				sel.hasIndex && sel.index == i,
+				sel.field != "" && yamlField(item, sel.field) == sel.value:
+				children = append(children, item)
+			}
+		}
+	}
+
+	var matches []*yaml.Node
+	for _, child := range children {
+		matches = append(matches, matchYAML(child, rest)...)
+	}
+	return matches
+}
+
+// yamlField returns the scalar value of key in a mapping node, or "" if it isn't one.
+func yamlField(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// replaceScalar takes the source line a scalar node starts on and returns it with the
+// scalar replaced by value, written in the same style where it can be. If comment isn't
+// empty it replaces the rest of the line as a trailing comment.
+func replaceScalar(line string, node *yaml.Node, value, comment string) (string, error) {
+	runes := []rune(line)
+	start := node.Column - 1
+	if start < 0 || start > len(runes) {
+		return "", errors.New("value position is outside the line")
+	}
+
+	var end int
+	var text string
+	switch node.Style &^ yaml.FlowStyle {
+	case yaml.DoubleQuotedStyle:
+		end = quotedEnd(runes, start, '"')
+		text = strconv.Quote(value)
+	case yaml.SingleQuotedStyle:
+		end = quotedEnd(runes, start, '\'')
+		text = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case 0:
+		end = plainEnd(runes, start)
+		if string(runes[start:end]) != node.Value {
+			return "", errors.New("multi-line values can't be set")
+		}
+		text = plainScalar(value, node.ShortTag())
+	default:
+		return "", errors.New("block scalars can't be set")
+	}
+	if end < 0 {
+		return "", errors.New("multi-line values can't be set")
+	}
+
+	rest := string(runes[end:])
+	if comment != "" {
+		rest = " # " + comment + lineEnding(rest)
+	}
+	return string(runes[:start]) + text + rest, nil
+}
+
+// lineEnding returns the line break a line ends with, if any.
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+// quotedEnd returns the index just past the closing quote of a quoted scalar opening at
+// start, or -1 if it doesn't close on the same line.
+func quotedEnd(runes []rune, start int, quote rune) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch {
+		case quote == '"' && runes[i] == '\\':
+			i++
+		case runes[i] == quote && quote == '\'' && i+1 < len(runes) && runes[i+1] == '\'':
+			i++
+		case runes[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// plainEnd returns the index just past a plain scalar starting at start, which ends at a
+// comment, the end of the line or, in a flow collection, a separator.
+func plainEnd(runes []rune, start int) int {
+	end := len(runes)
+	for i := start; i < len(runes); i++ {
+		if runes[i] == '\n' || runes[i] == '\r' ||
+			(runes[i] == '#' && i > start && (runes[i-1] == ' ' || runes[i-1] == '\t')) {
+			end = i
+			break
+		}
+		if (runes[i] == ',' || runes[i] == ']' || runes[i] == '}') && inFlow(runes[:start]) {
+			end = i
+			break
+		}
+	}
+	for end > start && (runes[end-1] == ' ' || runes[end-1] == '\t') {
+		end--
+	}
+	return end
+}
+
+// inFlow reports whether a line has an unclosed [ or { before a position.
+func inFlow(runes []rune) bool {
+	depth := 0
+	for _, r := range runes {
+		switch r {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// plainScalar takes a value and the tag of the plain scalar it replaces, i.e. !!str, and
+// returns the value as it should be written. It stays unquoted if YAML reads it back as the
+// same string, or as the same type as before without losing anything, so a replica count
+// stays a number. Otherwise it's quoted, so 1.10 doesn't become the float 1.1 and true
+// doesn't become a boolean.
+func plainScalar(value, tag string) string {
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(value), &node)
+	if err != nil || len(node.Content) != 1 {
+		return strconv.Quote(value)
+	}
+
+	scalar := node.Content[0]
+	if scalar.Kind != yaml.ScalarNode || scalar.Style != 0 || scalar.Value != value || strings.ContainsAny(value, "\n#") {
+		return strconv.Quote(value)
+	}
+
+	switch scalar.ShortTag() {
+	case "!!str":
+		return value
+	case tag:
+		var decoded interface{}
+		if scalar.Decode(&decoded) != nil {
+			return strconv.Quote(value)
+		}
+		out, err := yaml.Marshal(decoded)
+		if err == nil && strings.TrimSuffix(string(out), "\n") == value {
+			return value
+		}
+	}
+	return strconv.Quote(value)
+}
+
+// YAMLValues takes the contents of a YAML, or JSON, file and a path, as given to yaml-set,
+// and returns every value at that path. Lists and mappings are returned as flow YAML.
+func YAMLValues(src []byte, path string) ([]string, error) {
+	selectors, err := parseYAMLPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing: %w", err)
+		}
+
+		for _, node := range matchYAML(&doc, selectors) {
+			if node.Kind == yaml.ScalarNode {
+				values = append(values, node.Value)
+				continue
+			}
+
+			flow := *node
+			flow.Style = yaml.FlowStyle
+			out, err := yaml.Marshal(&flow)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, strings.TrimSpace(string(out)))
+		}
+	}
+	return values, nil
+}
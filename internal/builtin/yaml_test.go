@@ -0,0 +1,155 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// TestYAMLSet checks values are set by path and selector with comments, quoting and
+// unknown keys left as they were.
+func TestYAMLSet(t *testing.T) {
+	const workflow = `name: unit
+
+on: [push, pull_request]
+
+jobs:
+  test:
+    runs-on: ubuntu-latest # pinned by CI
+    steps:
+      - uses: actions/checkout@v3
+
+      # Terraform version used by the tests
+      - name: Setup Terraform
+        uses: hashicorp/setup-terraform@v2
+        with:
+          terraform_version: 0.14.8
+          terraform_wrapper: false
+      - name: Plan
+        run: terraform plan
+        env:
+          TF_VAR_region: 'eu-west-2'
+          TF_VAR_tags: "{ team = \"webops\" }"
+`
+
+	tests := []struct {
+		name      string
+		args      transform.Args
+		want      string
+		wantFiles int
+		wantErr   bool
+	}{
+		{
+			name: "selector",
+			args: transform.Args{"path": "jobs.*.steps[name=Setup Terraform].with.terraform_version", "value": "1.2.5"},
+			want: `          terraform_version: 1.2.5
+`,
+			wantFiles: 1,
+		},
+		{
+			name:      "single quoted",
+			args:      transform.Args{"path": "jobs.test.steps[2].env.TF_VAR_region", "value": "eu-west-1"},
+			want:      `          TF_VAR_region: 'eu-west-1'` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "double quoted",
+			args:      transform.Args{"path": "jobs.test.steps[name=Plan].env.TF_VAR_tags", "value": `{ team = "platform" }`},
+			want:      `          TF_VAR_tags: "{ team = \"platform\" }"` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "plain value needing quotes",
+			args:      transform.Args{"path": "jobs.test.runs-on", "value": "self-hosted # x"},
+			want:      `    runs-on: "self-hosted # x" # pinned by CI` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "version that would read as a float",
+			args:      transform.Args{"path": "jobs.test.steps[1].with.terraform_version", "value": "1.10"},
+			want:      `          terraform_version: "1.10"` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "string that would read as a boolean",
+			args:      transform.Args{"path": "jobs.test.steps[1].with.terraform_version", "value": "true"},
+			want:      `          terraform_version: "true"` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "string that would read as null",
+			args:      transform.Args{"path": "jobs.test.steps[1].with.terraform_version", "value": "null"},
+			want:      `          terraform_version: "null"` + "\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "boolean stays a boolean",
+			args:      transform.Args{"path": "jobs.test.steps[1].with.terraform_wrapper", "value": "true"},
+			want:      "          terraform_wrapper: true\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "flow sequence item",
+			args:      transform.Args{"path": "on[0]", "value": "workflow_dispatch"},
+			want:      "on: [workflow_dispatch, pull_request]\n",
+			wantFiles: 1,
+		},
+		{
+			name:      "no match",
+			args:      transform.Args{"path": "jobs.test.steps[name=Apply].run", "value": "terraform apply"},
+			wantFiles: 0,
+		},
+		{name: "mapping", args: transform.Args{"path": "jobs.test", "value": "x"}, wantErr: true},
+		{name: "no value", args: transform.Args{"path": "name"}, wantErr: true},
+		{name: "unclosed selector", args: transform.Args{"path": "jobs.test.steps[0", "value": "x"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".github", "workflows", "unit.yml")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(workflow), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := YAMLSet(context.Background(), &transform.Input{Dir: dir, Args: tt.args})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("YAMLSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(result.Files) != tt.wantFiles {
+				t.Errorf("YAMLSet() files = %v, want %d", result.Files, tt.wantFiles)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff := diffLines(workflow, string(got))
+			if diff != tt.want {
+				t.Errorf("YAMLSet() changed lines\n%s\nwant\n%s", diff, tt.want)
+			}
+		})
+	}
+}
+
+// diffLines returns the lines of got that differ from the same line of want.
+func diffLines(want, got string) string {
+	var diff string
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i, line := range gotLines {
+		if i >= len(wantLines) || wantLines[i] != line {
+			diff += line + "\n"
+		}
+	}
+	return diff
+}
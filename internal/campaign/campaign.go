@@ -0,0 +1,123 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a campaign file describing the ordered steps to run on each repository, i.e.
+//
+//	steps:
+//	  - name: upgrade
+//	    command: terraform 0.13upgrade -yes
+//	    loop: true
+//	  - name: rename
+//	    command: sed -i 's/old/{{.Repo.Name}}/' README.md
+//	    template: true
+//	  - name: format
+//	    script: ./fmt.sh
+//	    dir: modules
+//	    continue_on_failure: true
+//	  - name: require terraform 1.2
+//	    transform: hcl-set
+//	    args:
+//	      path: terraform.required_version
+//	      value: ">= 1.2.5"
+type Spec struct {
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is a single command, local script or built-in transformation run on each repository.
+type Step struct {
+	Name string `yaml:"name"`
+	// Command is a shell command, run as it is unless Template is set.
+	Command string `yaml:"command"`
+	// Template renders Command as a Go template per repository, like --template-command.
+	Template bool `yaml:"template"`
+	// Script is the path to a local script, relative to the campaign file, that is
+	// copied into each clone and executed.
+	Script string `yaml:"script"`
+	// Transform is the name of a registered transformation, i.e. hcl-set, given Args.
+	Transform string            `yaml:"transform"`
+	Args      map[string]string `yaml:"args"`
+	// Dir is the directory, relative to the repository root, to run in.
+	Dir string `yaml:"dir"`
+	// Loop runs the step in every directory below Dir.
+	Loop bool `yaml:"loop"`
+	// ContinueOnFailure lets the following steps run, and the changes be committed,
+	// when this step fails.
+	ContinueOnFailure bool `yaml:"continue_on_failure"`
+}
+
+// Load takes the path to a campaign file, parses it and checks every step is valid.
+// Script paths, and the source of sync steps, are made absolute.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec Spec
+	err = yaml.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing campaign file: %w", err)
+	}
+
+	if len(spec.Steps) == 0 {
+		return nil, errors.New("campaign file has no steps")
+	}
+
+	base := filepath.Dir(path)
+	for i := range spec.Steps {
+		step := &spec.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+
+		err := step.validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", step.Name, err)
+		}
+
+		if step.Script != "" && !filepath.IsAbs(step.Script) {
+			step.Script = filepath.Join(base, step.Script)
+		}
+
+		// Like a script, the template directory of a sync step is relative to the campaign file
+		if source := step.Args["source"]; step.Transform == "sync" && source != "" && !filepath.IsAbs(source) {
+			step.Args["source"] = filepath.Join(base, source)
+		}
+	}
+
+	return &spec, nil
+}
+
+func (s *Step) validate() error {
+	set := 0
+	for _, field := range []string{s.Command, s.Script, s.Transform} {
+		if field != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("a step needs exactly one of command, script or transform")
+	}
+	if s.Transform == "" && len(s.Args) > 0 {
+		return errors.New("args can only be given to a transform")
+	}
+	if s.Command == "" && s.Template {
+		return errors.New("template can only be set for a command")
+	}
+
+	dir := filepath.Clean(s.Dir)
+	if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("dir %q must be inside the repository", s.Dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package campaign
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoad checks steps are read in order with script paths resolved against the
+// campaign file, and that invalid steps are rejected.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: `steps:
+  - name: upgrade
+    command: echo {{.Repo.Name}}
+    template: true
+    loop: true
+  - script: fmt.sh
+    dir: modules
+    continue_on_failure: true
+`,
+		},
+		{name: "no steps", spec: "steps: []\n", wantErr: true},
+		{name: "command and script", spec: "steps:\n  - command: ls\n    script: fmt.sh\n", wantErr: true},
+		{name: "command and transform", spec: "steps:\n  - command: ls\n    transform: hcl-set\n", wantErr: true},
+		{name: "args without transform", spec: "steps:\n  - command: ls\n    args:\n      path: a\n", wantErr: true},
+		{name: "template without command", spec: "steps:\n  - script: fmt.sh\n    template: true\n", wantErr: true},
+		{name: "neither command nor script", spec: "steps:\n  - name: empty\n", wantErr: true},
+		{name: "dir outside repository", spec: "steps:\n  - command: ls\n    dir: ../other\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "campaign.yaml")
+			if err := os.WriteFile(path, []byte(tt.spec), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			spec, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(spec.Steps) != 2 {
+				t.Fatalf("Load() steps = %d, want 2", len(spec.Steps))
+			}
+			first, second := spec.Steps[0], spec.Steps[1]
+			if first.Name != "upgrade" || !first.Template || !first.Loop || first.ContinueOnFailure {
+				t.Errorf("first step = %+v", first)
+			}
+			if second.Name != "step 2" || second.Script != filepath.Join(dir, "fmt.sh") || second.Dir != "modules" || !second.ContinueOnFailure {
+				t.Errorf("second step = %+v", second)
+			}
+		})
+	}
+}
+
+// TestLoadSyncSource checks the template directory of a sync step is relative to the
+// campaign file, as scripts are, rather than wherever the cli is run from.
+func TestLoadSyncSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "campaign.yaml")
+	spec := "steps:\n  - transform: sync\n    args:\n      source: ./templates\n  - transform: sync\n    args:\n      source: /srv/templates\n"
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if source := got.Steps[0].Args["source"]; source != filepath.Join(dir, "templates") {
+		t.Errorf("relative source = %q, want %q", source, filepath.Join(dir, "templates"))
+	}
+	if source := got.Steps[1].Args["source"]; source != "/srv/templates" {
+		t.Errorf("absolute source = %q, want it unchanged", source)
+	}
+}
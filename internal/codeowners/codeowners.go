@@ -0,0 +1,163 @@
+package codeowners
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// locations are where GitHub looks for a CODEOWNERS file, in the order it looks.
+var locations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule is a single line of a CODEOWNERS file: a path pattern and its owners.
+type Rule struct {
+	Pattern string
+	Owners  []string
+	re      *regexp.Regexp
+}
+
+// Ruleset is every rule in a CODEOWNERS file, in file order.
+type Ruleset []Rule
+
+// Load takes a commit and parses the first CODEOWNERS file GitHub would use from its tree,
+// so it's found even when a sparse checkout left it out of the worktree. It returns a nil
+// Ruleset if the repository doesn't have one.
+func Load(commit *object.Commit) (Ruleset, error) {
+	for _, location := range locations {
+		f, err := commit.File(location)
+		if errors.Is(err, object.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return Parse(r)
+	}
+
+	return nil, nil
+}
+
+// Parse reads a CODEOWNERS file, ignoring blank lines and comments.
+func Parse(r io.Reader) (Ruleset, error) {
+	var rules Ruleset
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		re, err := compile(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, Rule{Pattern: fields[0], Owners: fields[1:], re: re})
+	}
+
+	return rules, scanner.Err()
+}
+
+// Owners takes a path relative to the repository root and returns the owners of the last
+// matching rule, as GitHub does.
+func (rs Ruleset) Owners(path string) []string {
+	path = filepath.ToSlash(path)
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i].re.MatchString(path) {
+			return rs[i].Owners
+		}
+	}
+
+	return nil
+}
+
+// Reviewers takes the changed files and the organisation the repository belongs to. It
+// returns the users and team slugs that own them, without duplicates. Email owners and
+// teams outside the organisation can't be requested as reviewers so are dropped.
+func (rs Ruleset) Reviewers(files []string, org string) (users, teams []string) {
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for _, owner := range rs.Owners(file) {
+			if !strings.HasPrefix(owner, "@") || seen[owner] {
+				continue
+			}
+			seen[owner] = true
+
+			name := strings.TrimPrefix(owner, "@")
+			if i := strings.Index(name, "/"); i >= 0 {
+				if strings.EqualFold(name[:i], org) {
+					teams = append(teams, name[i+1:])
+				}
+				continue
+			}
+			users = append(users, name)
+		}
+	}
+
+	return users, teams
+}
+
+// compile turns a CODEOWNERS pattern, which follows gitignore rules, into a regular
+// expression matching the paths it owns. A pattern without a leading or middle slash
+// matches at any depth, and a pattern matching a directory owns everything below it.
+func compile(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	// A pattern ending in a wildcard, i.e. docs/*, only matches the entries directly in
+	// that directory rather than everything below them.
+	last := pattern[strings.LastIndex(pattern, "/")+1:]
+	switch {
+	case dirOnly:
+		expr.WriteString("/.*$")
+	case strings.ContainsAny(last, "*?") && last != "**":
+		expr.WriteString("$")
+	default:
+		expr.WriteString("(/.*)?$")
+	}
+
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,141 @@
+package codeowners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const file = `# Default owners
+*                   @ministryofjustice/webops
+
+/modules/           @alice
+*.tf                @bob
+docs/*              @frank
+docs/**/*.md        @carol docs@example.com
+/.github/workflows/ @ministryofjustice/webops @dave
+README.md           @other-org/team
+`
+
+// TestOwners checks the last matching rule wins and that patterns are anchored
+// and matched against directories as GitHub does.
+func TestOwners(t *testing.T) {
+	rules, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"main.go", []string{"@ministryofjustice/webops"}},
+		{"modules/rds/outputs.go", []string{"@alice"}},
+		{"modules/rds/main.tf", []string{"@bob"}},
+		{"examples/main.tf", []string{"@bob"}},
+		{"docs/guides/deep/usage.md", []string{"@carol", "docs@example.com"}},
+		{"docs/usage.md", []string{"@carol", "docs@example.com"}},
+		{"docs/notes.txt", []string{"@frank"}},
+		{"docs/guides/notes.txt", []string{"@ministryofjustice/webops"}},
+		{"nested/modules/x.go", []string{"@ministryofjustice/webops"}},
+		{".github/workflows/unit.yml", []string{"@ministryofjustice/webops", "@dave"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rules.Owners(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Owners(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompileWildcard checks a pattern ending in a wildcard only matches files directly in
+// its directory, as GitHub does.
+func TestCompileWildcard(t *testing.T) {
+	re, err := compile("docs/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("docs/a.md") {
+		t.Error("docs/* doesn't match docs/a.md; want a match")
+	}
+	if re.MatchString("docs/a/b.md") {
+		t.Error("docs/* matches docs/a/b.md; want no match")
+	}
+}
+
+// TestReviewers checks owners are split into users and teams of the organisation.
+func TestReviewers(t *testing.T) {
+	rules, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, teams := rules.Reviewers([]string{".github/workflows/unit.yml", "docs/usage.md", "README.md", "main.go"}, "ministryofjustice")
+	if !reflect.DeepEqual(users, []string{"dave", "carol"}) {
+		t.Errorf("users = %v", users)
+	}
+	if !reflect.DeepEqual(teams, []string{"webops"}) {
+		t.Errorf("teams = %v", teams)
+	}
+}
+
+// TestLoad checks CODEOWNERS is read from the commit rather than the worktree, so it's
+// found when a sparse checkout left .github out.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+
+	// commit writes a file and commits it, returning the commit.
+	commit := func(name, content string) *object.Commit {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := tree.Commit("add "+name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	rules, err := Load(commit("README.md", "readme"))
+	if err != nil || rules != nil {
+		t.Errorf("Load() without a CODEOWNERS = %v, %v; want nil", rules, err)
+	}
+
+	withOwners := commit(".github/CODEOWNERS", file)
+	if err := os.RemoveAll(filepath.Join(dir, ".github")); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err = Load(withOwners)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := rules.Owners("modules/rds/outputs"); !reflect.DeepEqual(got, []string{"@alice"}) {
+		t.Errorf("Owners() = %v, want [@alice]", got)
+	}
+}
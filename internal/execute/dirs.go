@@ -0,0 +1,115 @@
+package execute
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirFilter narrows down the directories a command executes on in loop mode. The .git
+// directory is always skipped.
+type DirFilter struct {
+	// Include, if set, only executes on directories whose path, relative to the
+	// repository root, or name matches one of the globs. Their subdirectories are
+	// still walked.
+	Include []string
+	// Exclude skips directories, and everything below them, whose path or name matches
+	// one of the globs i.e. .terraform.
+	Exclude []string
+	// MaxDepth, if set, limits how many levels below the starting directory are walked.
+	MaxDepth int
+	// Contains, if set, only executes on directories holding a file whose name matches
+	// one of the globs i.e. *.tf.
+	Contains []string
+}
+
+// Dirs takes the repository root, the directory to start from and a filter. It returns
+// every directory below start, including start itself, the filter selects.
+func Dirs(root, start string, filter DirFilter) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(start, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if path != start && matchAny(filter.Exclude, rel) {
+			return filepath.SkipDir
+		}
+
+		selected, err := filter.selects(path, rel)
+		if err != nil {
+			return err
+		}
+		if selected {
+			dirs = append(dirs, path)
+		}
+
+		if filter.MaxDepth > 0 && depth(start, path) >= filter.MaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	return dirs, err
+}
+
+func (f DirFilter) selects(path, rel string) (bool, error) {
+	if len(f.Include) > 0 && !matchAny(f.Include, rel) {
+		return false, nil
+	}
+
+	if len(f.Contains) == 0 {
+		return true, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		for _, glob := range f.Contains {
+			if ok, _ := filepath.Match(glob, entry.Name()); ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// matchAny reports whether a relative path, or its last element, matches any of the globs.
+func matchAny(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func depth(start, path string) int {
+	rel, err := filepath.Rel(start, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
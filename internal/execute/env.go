@@ -0,0 +1,40 @@
+package execute
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// RepoEnv takes a GitHub repository, the directory it's cloned to and whether this is a
+// dry run. It returns the environment variables that tell a command where it's running:
+// GIT_XARGS_REPO, GIT_XARGS_ORG, GIT_XARGS_DEFAULT_BRANCH, GIT_XARGS_REPO_ROOT and
+// GIT_XARGS_DRY_RUN. GIT_XARGS_REL_DIR is set by Command for each directory.
+func RepoEnv(repo *github.Repository, dir string, dryRun bool) ([]string, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		"GIT_XARGS_REPO=" + repo.GetName(),
+		"GIT_XARGS_ORG=" + repo.GetOwner().GetLogin(),
+		"GIT_XARGS_DEFAULT_BRANCH=" + repo.GetDefaultBranch(),
+		"GIT_XARGS_REPO_ROOT=" + root,
+		"GIT_XARGS_DRY_RUN=" + strconv.FormatBool(dryRun),
+	}, nil
+}
+
+// ValidateEnv checks user supplied environment variables are in KEY=VALUE form.
+func ValidateEnv(pairs []string) error {
+	for _, pair := range pairs {
+		if strings.Index(pair, "=") < 1 {
+			return fmt.Errorf("invalid environment variable %q, want KEY=VALUE", pair)
+		}
+	}
+
+	return nil
+}
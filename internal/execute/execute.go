@@ -1,49 +1,264 @@
 package execute
 
 import (
+	"bytes"
+	"context"
 	"errors"
-	"io/fs"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 )
 
-// Command takes a directory path, a command to execute and a git
-// worktree. If a loop is specified, it'll execute the command argument on
-// every directory. Otherwise it'll just execute once on the root of the
-// repository. It outputs an error if found.
-func Command(dir, command string, tree *git.Worktree, loop bool) error {
+// Options change how Command executes.
+type Options struct {
+	// Dir is the directory, relative to the repository root, to execute in. With Loop
+	// the command executes on every directory below it.
+	Dir string
+	// Loop executes the command on every directory of the repository.
+	Loop bool
+	// Filter narrows down the directories the command executes on in loop mode.
+	Filter DirFilter
+	// Parallel is how many directories the command executes on at once in loop mode.
+	Parallel int
+	// ContinueOnError carries on executing in the remaining directories when one fails
+	// in loop mode, rather than stopping.
+	ContinueOnError bool
+	// ExitCodes define which exit codes count as success, skip or failure.
+	ExitCodes ExitCodes
+	// Output, if set, receives the command's stdout and stderr as it runs. In loop
+	// mode each line is prefixed with the directory it came from.
+	Output io.Writer
+	// Timeout, if set, limits how long each execution of the command may take.
+	Timeout time.Duration
+	// Env holds KEY=VALUE pairs added to the command's environment, i.e. from RepoEnv.
+	// GIT_XARGS_REL_DIR is always set to the directory relative to the repository root.
+	Env []string
+}
+
+// Result is the captured outcome of executing the command in a single directory.
+type Result struct {
+	Dir      string `json:"dir"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Err      error  `json:"-"`
+}
+
+// Command takes a context, a directory path, a command to execute, a git worktree and
+// options. If a loop is specified, it'll execute the command argument on every directory.
+// Otherwise it'll just execute once on the root of the repository. It returns the
+// captured output of every execution and an error, including the command's stderr,
+// if one fails. If the context is cancelled or an execution times out, the command and
+// every process it started are killed.
+func Command(ctx context.Context, dir, command string, tree *git.Worktree, opts Options) ([]Result, error) {
 	if len(command) < 1 {
-		return errors.New("no command executed")
+		return nil, errors.New("no command executed")
 	}
 
-	// if the loop switch is set to true, the chosen command will execute in every directory.
-	if loop {
-		err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				cmd := exec.Command("/bin/sh", "-c", command)
-				cmd.Dir = filepath.Dir(path) + "/" + info.Name()
-				err := cmd.Run()
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+	var results []Result
+	start := filepath.Join(dir, opts.Dir)
+
+	// if the loop switch is set to true, the chosen command will execute in every directory
+	// the filter selects.
+	if opts.Loop {
+		dirs, err := Dirs(dir, start, opts.Filter)
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		return runDirs(ctx, dir, dirs, command, opts)
 	} else {
-		cmd := exec.Command("/bin/sh", "-c", command)
-		cmd.Dir = dir
-		err := cmd.Run()
-		if err != nil {
-			return err
+		result := run(ctx, dir, start, command, opts)
+		results = append(results, result)
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+	return results, nil
+}
+
+// runDirs executes the command in each directory, opts.Parallel at a time. Results are
+// returned in directory order for those that ran. Once one fails no more are started,
+// unless opts.ContinueOnError is set, in which case every failure is counted.
+func runDirs(ctx context.Context, root string, dirs []string, command string, opts Options) ([]Result, error) {
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	if opts.Output != nil && parallel > 1 {
+		opts.Output = &lockedWriter{w: opts.Output}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  bool
+		ran     = make([]bool, len(dirs))
+		results = make([]Result, len(dirs))
+		sem     = make(chan struct{}, parallel)
+	)
+	for i, path := range dirs {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failed && !opts.ContinueOnError
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			break
 		}
+
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := run(ctx, root, path, command, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i], ran[i] = result, true
+			if result.Err != nil {
+				failed = true
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	var (
+		collected []Result
+		errs      []error
+	)
+	for i := range dirs {
+		if !ran[i] {
+			continue
+		}
+		collected = append(collected, results[i])
+		if results[i].Err != nil {
+			errs = append(errs, results[i].Err)
+		}
+	}
+
+	switch {
+	case len(errs) > 1:
+		return collected, fmt.Errorf("%d of %d directories failed, first: %w", len(errs), len(collected), errs[0])
+	case len(errs) == 1:
+		return collected, errs[0]
+	case ctx.Err() != nil:
+		return collected, ctx.Err()
+	}
+	return collected, nil
+}
+
+// run executes the command in path, a directory below the repository root, capturing
+// its output.
+func run(ctx context.Context, root, path, command string, opts Options) Result {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
 	}
-	return nil
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Dir = path
+	cmd.Env = append(append(os.Environ(), opts.Env...), "GIT_XARGS_REL_DIR="+rel)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if opts.Output != nil {
+		out := opts.Output
+		if opts.Loop {
+			out = NewPrefixWriter(out, "["+rel+"] ")
+		}
+		cmd.Stdout = io.MultiWriter(&stdout, out)
+		cmd.Stderr = io.MultiWriter(&stderr, out)
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	result := Result{Dir: rel}
+	err = runProcessGroup(ctx, cmd)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+	}
+
+	result.Status = opts.ExitCodes.status(result.ExitCode)
+	if ctx.Err() != nil {
+		result.Status = StatusFailure
+	}
+
+	if result.Status == StatusFailure {
+		if err == nil {
+			err = fmt.Errorf("exit status %d", result.ExitCode)
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			err = fmt.Errorf("timed out after %s: %w", opts.Timeout, ctx.Err())
+		case context.Canceled:
+			err = fmt.Errorf("cancelled: %w", ctx.Err())
+		}
+		result.Err = fmt.Errorf("%s: %w%s", rel, err, tail(result.Stderr))
+	}
+
+	return result
+}
+
+// runProcessGroup starts the command in its own process group and waits for it. If the
+// context is done first the whole group is killed, so nothing the command started is
+// left behind.
+func runProcessGroup(ctx context.Context, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+// tail returns the last few lines of a command's output, formatted to be appended
+// to an error message.
+func tail(output string) string {
+	const max = 5
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+
+	return ": " + strings.Join(lines, "\n")
 }
@@ -1,11 +1,16 @@
 package execute
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/google/go-github/v35/github"
@@ -33,7 +38,7 @@ func createMock() (repoDir string, tree *git.Worktree) {
 	repo := mockRepo()
 	client := github.NewClient(nil)
 
-	repoDir, localRepo, _ := local.Clone(repo, client)
+	repoDir, localRepo, _ := local.Clone(context.Background(), repo, client, local.CloneOptions{})
 
 	tree, _ = localRepo.Worktree()
 
@@ -59,7 +64,7 @@ func TestCommandLoop(t *testing.T) {
 
 	// Set loop to false and ensure command is run once and that the file is only
 	// created in a single dir. If it exists in a child dir it'll fail.
-	err := Command(repoDir, "touch file.md", tree, false)
+	_, err := Command(context.Background(), repoDir, "touch file.md", tree, Options{})
 	if err != nil {
 		t.Error("Unable to run command when loop == false")
 	}
@@ -69,16 +74,19 @@ func TestCommandLoop(t *testing.T) {
 	}
 
 	// Set loop to true. Will pass if a file called file.md exists in each directory.
-	err = Command(repoDir, "touch file.md", tree, true)
+	_, err = Command(context.Background(), repoDir, "touch file.md", tree, Options{Loop: true})
 	if err != nil {
 		t.Error("Unable to execute command when loop == true")
 	}
 
-	// Walk all directories in the repository and look for existance of file in each dir.
+	// Walk all directories in the repository, bar .git, and look for existance of file in each dir.
 	_ = filepath.Walk(repoDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			t.Error("Unable to walk the tree. Fail.")
 		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
 		if info.IsDir() {
 			_, err = os.Stat(path + "/file.md")
 			if os.IsNotExist(err) {
@@ -97,14 +105,240 @@ func TestInvalidCommand(t *testing.T) {
 	repoDir, tree := createMock()
 
 	// Send an empty command and expect a failure.
-	err := Command(repoDir, "", tree, false)
+	_, err := Command(context.Background(), repoDir, "", tree, Options{})
 	if err == nil {
 		t.Error("When provided with an empty string; want fail, got continue")
 	}
 
 	// Send a false command and expect a failure.
-	err = Command(repoDir, "NOTACOMMAND", tree, false)
+	_, err = Command(context.Background(), repoDir, "NOTACOMMAND", tree, Options{})
 	if err == nil {
 		t.Error("A command that should of failed, passed; want error, got success.")
 	}
 }
+
+// TestCommandOutput checks stdout and stderr are captured per directory, streamed
+// with the directory prefix in loop mode and included in the error on failure.
+func TestCommandOutput(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	results, err := Command(context.Background(), dir, "echo out; echo err >&2", nil, Options{Loop: true, Output: &out})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+
+	if len(results) != 2 || results[1].Dir != "modules" || results[1].Stdout != "out\n" || results[1].Stderr != "err\n" {
+		t.Errorf("Command() results = %+v", results)
+	}
+	if !strings.Contains(out.String(), "[modules] out\n") {
+		t.Errorf("streamed output = %q, want lines prefixed with the directory", out.String())
+	}
+
+	results, err = Command(context.Background(), dir, "echo broken >&2; exit 3", nil, Options{})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Command() error = %v, want it to include stderr", err)
+	}
+	if len(results) != 1 || results[0].ExitCode != 3 {
+		t.Errorf("Command() results = %+v, want exit code 3", results)
+	}
+}
+
+// TestCommandTimeout checks a command running past its timeout is killed along with
+// the processes it started.
+func TestCommandTimeout(t *testing.T) {
+	dir := t.TempDir()
+
+	start := time.Now()
+	_, err := Command(context.Background(), dir, "sleep 10 & sleep 10; touch done", nil, Options{Timeout: 100 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Command() error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Command() waited for the command to finish; want it killed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "done")); err == nil {
+		t.Error("command carried on after timing out")
+	}
+}
+
+// TestCommandEnv checks the repository context and user variables reach the command,
+// with the relative directory set per directory in loop mode.
+func TestCommandEnv(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &github.Repository{
+		Name:          github.String("cloud-platform-terraform-rds"),
+		Owner:         &github.User{Login: github.String("ministryofjustice")},
+		DefaultBranch: github.String("main"),
+	}
+	env, err := RepoEnv(repo, dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := []string{"TARGET=1.2.5"}
+	if err := ValidateEnv(user); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Command(context.Background(), dir, `echo "$GIT_XARGS_ORG/$GIT_XARGS_REPO@$GIT_XARGS_DEFAULT_BRANCH $GIT_XARGS_REL_DIR $GIT_XARGS_DRY_RUN $TARGET"`, nil, Options{Loop: true, Env: append(env, user...)})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+
+	want := []string{
+		"ministryofjustice/cloud-platform-terraform-rds@main . true 1.2.5\n",
+		"ministryofjustice/cloud-platform-terraform-rds@main modules true 1.2.5\n",
+	}
+	for i, result := range results {
+		if result.Stdout != want[i] {
+			t.Errorf("Stdout = %q, want %q", result.Stdout, want[i])
+		}
+	}
+
+	if err := ValidateEnv([]string{"=nokey"}); err == nil {
+		t.Error("ValidateEnv() of a pair without a key succeeded; want error")
+	}
+}
+
+// TestDirs checks loop mode skips .git and honours the include, exclude, depth and
+// contains filters.
+func TestDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		".git/objects/pack",
+		"modules/rds/.terraform/providers",
+		"modules/s3",
+		"namespaces/live/app",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"modules/rds/main.tf", "namespaces/live/app/main.tf", "modules/s3/README.md"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		start  string
+		filter DirFilter
+		want   []string
+	}{
+		{
+			name:   "skips .git",
+			filter: DirFilter{Exclude: []string{".terraform"}},
+			want:   []string{".", "modules", "modules/rds", "modules/s3", "namespaces", "namespaces/live", "namespaces/live/app"},
+		},
+		{
+			name:   "contains terraform",
+			filter: DirFilter{Contains: []string{"*.tf"}},
+			want:   []string{"modules/rds", "namespaces/live/app"},
+		},
+		{
+			name:   "max depth from start",
+			start:  "namespaces",
+			filter: DirFilter{MaxDepth: 1},
+			want:   []string{"namespaces", "namespaces/live"},
+		},
+		{
+			name:   "include and exclude",
+			filter: DirFilter{Include: []string{"modules/*"}, Exclude: []string{"s3"}},
+			want:   []string{"modules/rds"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs, err := Dirs(root, filepath.Join(root, tt.start), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, dir := range dirs {
+				rel, _ := filepath.Rel(root, dir)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Dirs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCommandParallel checks directories run concurrently, that every failure is
+// counted when continuing on error and that results keep directory order.
+func TestCommandParallel(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	results, err := Command(context.Background(), dir, "sleep 0.5", nil, Options{Loop: true, Parallel: 4})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if len(results) != 4 || time.Since(start) > 1500*time.Millisecond {
+		t.Errorf("Command() ran %d directories in %s, want 4 concurrently", len(results), time.Since(start))
+	}
+
+	results, err = Command(context.Background(), dir, `[ "$GIT_XARGS_REL_DIR" = b ] || exit 1`, nil, Options{Loop: true, Parallel: 2, ContinueOnError: true})
+	if err == nil || !strings.Contains(err.Error(), "3 of 4 directories failed") {
+		t.Errorf("Command() error = %v, want 3 of 4 failures", err)
+	}
+	var dirs []string
+	for _, result := range results {
+		dirs = append(dirs, result.Dir)
+	}
+	if strings.Join(dirs, " ") != ". a b c" || results[2].ExitCode != 0 || results[3].ExitCode != 1 {
+		t.Errorf("Command() results = %+v", results)
+	}
+}
+
+// TestCommandExitCodes checks exit codes are classed as success, skip or failure, and
+// that a repository where every directory skipped is reported as skipped.
+func TestCommandExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	codes := ExitCodes{Success: []int{0, 3}, Skip: []int{2}}
+
+	tests := []struct {
+		command     string
+		wantStatus  string
+		wantErr     bool
+		wantSkipped bool
+	}{
+		{"exit 0", StatusSuccess, false, false},
+		{"exit 3", StatusSuccess, false, false},
+		{"exit 2", StatusSkip, false, true},
+		{"exit 1", StatusFailure, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			results, err := Command(context.Background(), dir, tt.command, nil, Options{ExitCodes: codes})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Command() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if results[0].Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", results[0].Status, tt.wantStatus)
+			}
+			if Skipped(results) != tt.wantSkipped {
+				t.Errorf("Skipped() = %v, want %v", Skipped(results), tt.wantSkipped)
+			}
+		})
+	}
+
+	// Without any configured, only 0 is a success.
+	if _, err := Command(context.Background(), dir, "exit 3", nil, Options{}); err == nil {
+		t.Error("Command() exiting 3 with default exit codes succeeded; want error")
+	}
+}
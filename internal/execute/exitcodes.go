@@ -0,0 +1,56 @@
+package execute
+
+// Statuses an execution of the command can finish with.
+const (
+	StatusSuccess = "success"
+	StatusSkip    = "skip"
+	StatusFailure = "failure"
+)
+
+// ExitCodes define what the command's exit codes mean. Tools like `terraform fmt -check`
+// exit non-zero when they change something rather than when they fail.
+type ExitCodes struct {
+	// Success are the exit codes that count as success. If empty, only 0 does.
+	Success []int
+	// Skip are the exit codes meaning there was nothing to do in the directory.
+	Skip []int
+}
+
+// status returns what an exit code means. Any code that isn't a success or a skip is
+// a failure.
+func (e ExitCodes) status(code int) string {
+	success := e.Success
+	if len(success) == 0 {
+		success = []int{0}
+	}
+
+	switch {
+	case code < 0:
+		return StatusFailure
+	case contains(success, code):
+		return StatusSuccess
+	case contains(e.Skip, code):
+		return StatusSkip
+	}
+	return StatusFailure
+}
+
+// Skipped reports whether every execution in results exited with a skip code, meaning
+// there's nothing to commit in the repository.
+func Skipped(results []Result) bool {
+	for _, result := range results {
+		if result.Status != StatusSkip {
+			return false
+		}
+	}
+	return len(results) > 0
+}
+
+func contains(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
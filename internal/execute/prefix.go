@@ -0,0 +1,56 @@
+package execute
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter writes every line it's given to the underlying writer with a prefix.
+type prefixWriter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	prefix      []byte
+	atLineStart bool
+}
+
+// NewPrefixWriter takes a writer and a prefix, i.e. the repository name, and returns a
+// writer that prefixes each line written to it. It's safe for concurrent use.
+func NewPrefixWriter(w io.Writer, prefix string) io.Writer {
+	return &prefixWriter{w: w, prefix: []byte(prefix), atLineStart: true}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if p.atLineStart {
+			buf.Write(p.prefix)
+		}
+		buf.Write(line)
+		p.atLineStart = line[len(line)-1] == '\n'
+	}
+
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// lockedWriter serialises writes to a writer shared by concurrent commands.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
@@ -0,0 +1,34 @@
+package execute
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// InstallScript takes the root of a clone and the path to a local script. It copies the
+// script into the clone's .git directory, where it's executable but never committed, and
+// returns its absolute path.
+func InstallScript(dir, script string) (string, error) {
+	data, err := os.ReadFile(script)
+	if err != nil {
+		return "", err
+	}
+
+	scriptDir, err := filepath.Abs(filepath.Join(dir, ".git", "git-xargs"))
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(scriptDir, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(scriptDir, filepath.Base(script))
+	err = os.WriteFile(path, data, 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -44,24 +44,69 @@ func FetchRepositories(client *github.Client, org, blob, filePath string) (allRe
 }
 
 func getReposFromFile(filePath string) ([]string, error) {
+	lines, err := readRepoFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var text []string
+	for _, fields := range lines {
+		text = append(text, fields[0])
+	}
+	fmt.Println("Repositories fetched from file: ", text)
+
+	return text, nil
+}
+
+// RepoOverrides takes the path to a file containing a list of repositories. Each line
+// holds a repository name optionally followed by key=value pairs, i.e.
+// "cloud-platform-terraform-rds label=rds,terraform draft=true". It returns the pairs
+// keyed by repository name.
+func RepoOverrides(filePath string) (map[string]map[string]string, error) {
+	lines, err := readRepoFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]map[string]string)
+	for _, fields := range lines {
+		for _, field := range fields[1:] {
+			i := strings.Index(field, "=")
+			if i < 1 {
+				return nil, fmt.Errorf("invalid option %q for repository %s, want key=value", field, fields[0])
+			}
+			if overrides[fields[0]] == nil {
+				overrides[fields[0]] = make(map[string]string)
+			}
+			overrides[fields[0]][field[:i]] = field[i+1:]
+		}
+	}
+
+	return overrides, nil
+}
+
+// readRepoFile returns the whitespace separated fields of each non-empty line in the file.
+func readRepoFile(filePath string) ([][]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 
 	scanner.Split(bufio.ScanLines)
-	var text []string
+	var lines [][]string
 
 	for scanner.Scan() {
-		text = append(text, scanner.Text())
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		lines = append(lines, fields)
 	}
-	fmt.Println("Repositories fetched from file: ", text)
-
-	file.Close()
 
-	return text, nil
+	return lines, scanner.Err()
 }
 
 func FetchRepositoriesFromList(client *github.Client, repos []string, org string) ([]*github.Repository, error) {
@@ -1,6 +1,9 @@
 package get
 
 import (
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -93,3 +96,78 @@ func TestFetchRepositories(t *testing.T) {
 		})
 	}
 }
+
+// TestRepoOverrides checks the key=value pairs after a repository name in a list
+// file are returned, and that the names alone are still read as repositories.
+func TestRepoOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repos.txt")
+	content := "cloud-platform-terraform-rds label=rds,terraform draft=true\n\ncloud-platform-terraform-s3\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := RepoOverrides(path)
+	if err != nil {
+		t.Fatalf("RepoOverrides() error = %v", err)
+	}
+	want := map[string]map[string]string{
+		"cloud-platform-terraform-rds": {"label": "rds,terraform", "draft": "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RepoOverrides() = %v, want %v", got, want)
+	}
+
+	names, err := getReposFromFile(path)
+	if err != nil {
+		t.Fatalf("getReposFromFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"cloud-platform-terraform-rds", "cloud-platform-terraform-s3"}) {
+		t.Errorf("getReposFromFile() = %v", names)
+	}
+
+	if err := os.WriteFile(path, []byte("cloud-platform-terraform-rds draft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RepoOverrides(path); err == nil {
+		t.Error("RepoOverrides() with an option missing its value succeeded; want error")
+	}
+}
+
+// TestOpenPullRequest checks the open pull request is looked up by the campaign's head
+// branch, and that nil is returned when there isn't one.
+func TestOpenPullRequest(t *testing.T) {
+	repo := &github.Repository{
+		Name:  github.String("cloud-platform-terraform-rds"),
+		Owner: &github.User{Login: github.String("ministryofjustice")},
+	}
+
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if query.Get("state") != "open" || query.Get("head") != "ministryofjustice:update-tf-action" {
+					_, _ = w.Write([]byte(`[]`))
+					return
+				}
+				_, _ = w.Write([]byte(`[{"number": 7}]`))
+			}),
+		),
+	))
+
+	pull, err := OpenPullRequest(client, repo, "update-tf-action")
+	if err != nil {
+		t.Fatalf("OpenPullRequest() error = %v", err)
+	}
+	if pull.GetNumber() != 7 {
+		t.Errorf("OpenPullRequest() = %v, want pull request 7", pull)
+	}
+
+	pull, err = OpenPullRequest(client, repo, "another-campaign")
+	if err != nil {
+		t.Fatalf("OpenPullRequest() error = %v", err)
+	}
+	if pull != nil {
+		t.Errorf("OpenPullRequest() = %v, want nil", pull)
+	}
+}
@@ -0,0 +1,67 @@
+package get
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// MergedPullRequests takes a GitHub client, a repository, a branch name and an optional title
+// pattern. It returns every merged pull request raised from that branch, newest first. If title
+// isn't empty only pull requests whose title contains it are returned.
+func MergedPullRequests(client *github.Client, repo *github.Repository, branch, title string) ([]*github.PullRequest, error) {
+	ctx := context.Background()
+	owner := repo.GetOwner().GetLogin()
+	opt := &github.PullRequestListOptions{
+		State:       "closed",
+		Head:        owner + ":" + branch,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var merged []*github.PullRequest
+	for {
+		prs, resp, err := client.PullRequests.List(ctx, owner, repo.GetName(), opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range prs {
+			if pr.MergedAt == nil || pr.GetMergeCommitSHA() == "" {
+				continue
+			}
+			if title != "" && !strings.Contains(pr.GetTitle(), title) {
+				continue
+			}
+			merged = append(merged, pr)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return merged, nil
+}
+
+// OpenPullRequest takes a GitHub client, a repository and a branch name. It returns the open
+// pull request raised from that branch, or nil if there isn't one.
+func OpenPullRequest(client *github.Client, repo *github.Repository, branch string) (*github.PullRequest, error) {
+	owner := repo.GetOwner().GetLogin()
+	opt := &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + branch,
+	}
+
+	prs, _, err := client.PullRequests.List(context.Background(), owner, repo.GetName(), opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prs) == 0 {
+		return nil, nil
+	}
+
+	return prs[0], nil
+}
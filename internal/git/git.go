@@ -10,68 +10,130 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-github/v35/github"
 )
 
-// PushChanges takes a GitHub client, a branch, tree and repository. It first adds all changes to the git staging area, then commits,
-// pushes and creates a PR, outputting any errors.
-func PushChanges(client *github.Client, branch string, tree *git.Worktree, repo, message string, localRepo *git.Repository, remoteRepo *github.Repository) error {
-	defaultBranch := remoteRepo.GetDefaultBranch()
-	status, err := tree.Status()
+// ErrNoChanges is returned by Commit when the command left the worktree clean.
+var ErrNoChanges = errors.New("warning: no changes to commit")
+
+// Commit takes a local repository, its worktree and a commit message. It stages all changes,
+// including untracked files and deletions, and commits them, returning the commit. Files left
+// out of a sparse checkout are kept as they are rather than committed as deleted.
+func Commit(localRepo *git.Repository, tree *git.Worktree, message string) (*object.Commit, error) {
+	// go-git's status reports skip-worktree files as deleted, so they're set aside while
+	// the changes are staged.
+	skipped, err := unskip(localRepo)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if status.IsClean() {
-		return errors.New("warning: no changes to commit")
+	changed, err := stage(tree, skipped)
+	if err != nil {
+		reskip(localRepo, skipped)
+		return nil, err
 	}
 
-	for path := range status {
-		if status.IsUntracked(path) {
-			_, err := tree.Add(path)
-			if err != nil {
-				return nil
-			}
-		}
+	err = reskip(localRepo, skipped)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = tree.Commit(message, &git.CommitOptions{
-		All: true,
-	})
+	if !changed {
+		return nil, ErrNoChanges
+	}
+
+	hash, err := tree.Commit(message, &git.CommitOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = localRepo.Push(&git.PushOptions{
+	return localRepo.CommitObject(hash)
+}
+
+// stage takes a worktree and the files to leave alone, and stages every other change. It
+// reports whether anything is staged.
+func stage(tree *git.Worktree, skipped map[string]*index.Entry) (bool, error) {
+	status, err := tree.Status()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for path, s := range status {
+		if _, ok := skipped[path]; ok {
+			continue
+		}
+
+		switch s.Worktree {
+		case git.Unmodified:
+			changed = changed || s.Staging != git.Unmodified
+			continue
+		case git.Deleted:
+			_, err = tree.Remove(path)
+		default:
+			_, err = tree.Add(path)
+		}
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// Push takes a context, a local and remote repository and pushes the local branches to
+// the origin remote, authenticating with the GITHUB_OAUTH_TOKEN.
+func Push(ctx context.Context, localRepo *git.Repository, remoteRepo *github.Repository) error {
+	return localRepo.PushContext(ctx, &git.PushOptions{
 		RemoteName: "origin",
 		Auth: &http.BasicAuth{
 			Username: remoteRepo.GetOwner().GetLogin(),
 			Password: os.Getenv("GITHUB_OAUTH_TOKEN"),
 		},
 	})
-	if err != nil {
-		return err
-	}
+}
 
+// CreatePullRequest takes a GitHub client, a remote repository, the branch to merge
+// along with a title, body and whether it's a draft. It opens a pull request against
+// the default branch of the repository and returns it.
+func CreatePullRequest(client *github.Client, remoteRepo *github.Repository, branch, title, body string, draft bool) (*github.PullRequest, error) {
 	createPR := &github.NewPullRequest{
-		Title: github.String(message),
+		Title: github.String(title),
 		Head:  github.String(branch),
-		Base:  github.String(defaultBranch),
+		Base:  github.String(remoteRepo.GetDefaultBranch()),
+		Draft: github.Bool(draft),
 	}
 
-	_, _, err = client.PullRequests.Create(context.Background(), *remoteRepo.GetOwner().Login, remoteRepo.GetName(), createPR)
+	if body != "" {
+		createPR.Body = github.String(body)
+	}
+
+	pr, _, err := client.PullRequests.Create(context.Background(), remoteRepo.GetOwner().GetLogin(), remoteRepo.GetName(), createPR)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return pr, nil
 }
 
-// Checkout takes a GitHub client, a git reference and tree, along with local and remote repository.
-// It will create a branch with the hardcoded name 'update', and will output a new git reference.
-func Checkout(client *github.Client, ref *plumbing.Reference, tree *git.Worktree, remote *github.Repository, local *git.Repository) (plumbing.ReferenceName, error) {
-	branchName := plumbing.NewBranchReferenceName("update-tf-action")
+// Checkout takes a GitHub client, a branch name, a git reference and tree, along with local and remote
+// repository. It will create the branch from the reference, and will output a new git reference.
+func Checkout(client *github.Client, branch string, ref *plumbing.Reference, tree *git.Worktree, remote *github.Repository, local *git.Repository) (plumbing.ReferenceName, error) {
+	branchName := plumbing.NewBranchReferenceName(branch)
+
+	// Checking out resets the worktree, which would fill in a sparse checkout, so the
+	// branch is created where it stands instead.
+	sparse, err := isSparse(local)
+	if err != nil {
+		return "", err
+	}
+	if sparse {
+		return branchName, createBranch(local, branchName, ref.Hash())
+	}
 
 	create := &git.CheckoutOptions{
 		Hash:   ref.Hash(),
@@ -79,7 +141,7 @@ func Checkout(client *github.Client, ref *plumbing.Reference, tree *git.Worktree
 		Create: true,
 	}
 
-	err := tree.Checkout(create)
+	err = tree.Checkout(create)
 	if err != nil {
 		return "", err
 	}
@@ -87,10 +149,22 @@ func Checkout(client *github.Client, ref *plumbing.Reference, tree *git.Worktree
 	return branchName, nil
 }
 
-// Clone takes a GitHub repository and client. It will look to create a local copy of the
-// repository in the `tmp/` directory. It will then output the repository directory, name and
-// an error if there is one.
-func Clone(repo *github.Repository, token *github.Client) (string, *git.Repository, error) {
+// CloneOptions controls how much of a repository Clone fetches and checks out. The zero
+// value clones every branch with its full history.
+type CloneOptions struct {
+	// Depth limits the history fetched to this many commits. Zero fetches all of it.
+	Depth int
+	// SingleBranch fetches only the repository's default branch.
+	SingleBranch bool
+	// Sparse checks out only these directories, along with the files at the root of
+	// the repository.
+	Sparse []string
+}
+
+// Clone takes a context, a GitHub repository and client, and options for the clone. It will look
+// to create a local copy of the repository in the `tmp/` directory. It will then output the
+// repository directory, name and an error if there is one.
+func Clone(ctx context.Context, repo *github.Repository, token *github.Client, opts CloneOptions) (string, *git.Repository, error) {
 	tmpDir := "./tmp"
 	if _, err := os.Stat(tmpDir); os.IsNotExist(err) {
 		file := filepath.Join(".", tmpDir)
@@ -103,16 +177,42 @@ func Clone(repo *github.Repository, token *github.Client) (string, *git.Reposito
 		return "", nil, err
 	}
 
-	localRepo, err := git.PlainClone(repoDir, false, &git.CloneOptions{
-		URL: repo.GetCloneURL(),
+	localRepo, err := cloneTo(ctx, repoDir, repo, opts)
+	if err != nil {
+		return repoDir, nil, err
+	}
+
+	return repoDir, localRepo, nil
+}
+
+// cloneTo takes a context, a directory, a GitHub repository and options for the clone, and
+// clones the repository into the directory.
+func cloneTo(ctx context.Context, dir string, repo *github.Repository, opts CloneOptions) (*git.Repository, error) {
+	cloneOpts := &git.CloneOptions{
+		URL:          repo.GetCloneURL(),
+		Depth:        opts.Depth,
+		SingleBranch: opts.SingleBranch,
+		NoCheckout:   len(opts.Sparse) > 0,
 		Auth: &http.BasicAuth{
 			Username: repo.GetOwner().GetLogin(),
 			Password: os.Getenv("GITHUB_OAUTH_TOKEN"),
 		},
-	})
+	}
+	if opts.SingleBranch && repo.GetDefaultBranch() != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(repo.GetDefaultBranch())
+	}
+
+	localRepo, err := git.PlainCloneContext(ctx, dir, false, cloneOpts)
 	if err != nil {
-		return repoDir, nil, err
+		return nil, err
 	}
 
-	return repoDir, localRepo, nil
+	if len(opts.Sparse) > 0 {
+		err = SparseCheckout(localRepo, opts.Sparse)
+		if err != nil {
+			return nil, fmt.Errorf("error checking out %v: %w", opts.Sparse, err)
+		}
+	}
+
+	return localRepo, nil
 }
@@ -0,0 +1,138 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Revert takes a local repository, its worktree, the hash of a (merge) commit and a commit message.
+// It applies the inverse of the changes the commit introduced relative to its first parent to the
+// worktree and commits the result, returning the hash of the new commit. As go-git has no merge
+// machinery, a file that has changed again since the commit is treated as a conflict and nothing
+// is written.
+func Revert(localRepo *git.Repository, tree *git.Worktree, hash plumbing.Hash, message string) (plumbing.Hash, error) {
+	commit, err := localRepo.CommitObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if commit.NumParents() == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("commit %s has no parent to revert to", hash)
+	}
+
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commitTree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	changes, err := object.DiffTree(parentTree, commitTree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// Check every file before touching the worktree so a conflict leaves it clean.
+	for _, change := range changes {
+		from, to, err := change.Files()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if err := checkUnchanged(tree, from, to); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	for _, change := range changes {
+		from, to, err := change.Files()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		// The commit added the file, so reverting it removes it.
+		if from == nil {
+			if _, err := tree.Remove(to.Name); err != nil {
+				return plumbing.ZeroHash, err
+			}
+			continue
+		}
+
+		contents, err := from.Contents()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		mode, err := from.Mode.ToOSFileMode()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		f, err := tree.Filesystem.OpenFile(from.Name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if _, err := f.Write([]byte(contents)); err != nil {
+			f.Close()
+			return plumbing.ZeroHash, err
+		}
+		f.Close()
+
+		if _, err := tree.Add(from.Name); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return tree.Commit(message, &git.CommitOptions{})
+}
+
+// checkUnchanged makes sure the worktree copy of a file still matches the version
+// introduced by the commit being reverted. A nil to file means the commit deleted it,
+// so it must still be absent.
+func checkUnchanged(tree *git.Worktree, from, to *object.File) error {
+	if to == nil {
+		if _, err := tree.Filesystem.Stat(from.Name); err == nil {
+			return fmt.Errorf("conflict: %s has been recreated since the commit", from.Name)
+		}
+		return nil
+	}
+
+	f, err := tree.Filesystem.Open(to.Name)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("conflict: %s has been deleted since the commit", to.Name)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	current, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	contents, err := to.Contents()
+	if err != nil {
+		return err
+	}
+
+	if string(current) != contents {
+		return fmt.Errorf("conflict: %s has changed since the commit", to.Name)
+	}
+
+	return nil
+}
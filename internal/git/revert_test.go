@@ -0,0 +1,92 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFiles writes the given files to the worktree, removing any with empty
+// content, and commits them.
+func commitFiles(t *testing.T, dir string, tree *git.Worktree, files map[string]string) plumbing.Hash {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			if _, err := tree.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := tree.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// TestRevert checks a commit's modifications, additions and deletions are undone,
+// and that a file changed since the commit is reported as a conflict.
+func TestRevert(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+
+	// Revert takes its author from git config, as a campaign commit does.
+	cfg, _ := repo.Config()
+	cfg.User.Name = "test"
+	cfg.User.Email = "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	commitFiles(t, dir, tree, map[string]string{"versions.tf": "old", "removed.tf": "gone"})
+	campaign := commitFiles(t, dir, tree, map[string]string{"versions.tf": "new", "added.tf": "added", "removed.tf": ""})
+	commitFiles(t, dir, tree, map[string]string{"unrelated.tf": "other"})
+
+	_, err = Revert(repo, tree, campaign, "Revert")
+	if err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	want := map[string]string{"versions.tf": "old", "removed.tf": "gone", "unrelated.tf": "other"}
+	for name, content := range want {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "added.tf")); !os.IsNotExist(err) {
+		t.Error("added.tf still exists; want it removed")
+	}
+
+	status, _ := tree.Status()
+	if !status.IsClean() {
+		t.Errorf("worktree not clean after revert: %v", status)
+	}
+
+	// The campaign's change to versions.tf has now been reverted, so reverting again conflicts.
+	_, err = Revert(repo, tree, campaign, "Revert")
+	if err == nil {
+		t.Error("Revert() of a changed file succeeded; want conflict")
+	}
+}
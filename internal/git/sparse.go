@@ -0,0 +1,238 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// SparseCheckout takes a repository cloned without checking out and the directories to check
+// out. It writes the files of HEAD below those directories, and at the root of the repository,
+// to the worktree. Every other file, and every submodule, is recorded in the index as
+// skip-worktree, so it's left out of the worktree without being committed as deleted.
+//
+// go-git's own sparse checkout only marks files already in the index, so does nothing for a
+// fresh clone.
+func SparseCheckout(localRepo *git.Repository, dirs []string) error {
+	head, err := localRepo.Head()
+	if err != nil {
+		return err
+	}
+
+	commit, err := localRepo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	root, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	tree, err := localRepo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	// commit.Files() leaves out submodules, which would then be committed as deleted, so
+	// the tree is walked instead.
+	walker := object.NewTreeWalker(root, true, nil)
+	defer walker.Close()
+
+	idx := &index.Index{Version: 3}
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+
+		indexEntry := &index.Entry{
+			Name: name,
+			Hash: entry.Hash,
+			Mode: entry.Mode,
+		}
+		idx.Entries = append(idx.Entries, indexEntry)
+
+		if entry.Mode == filemode.Submodule || !inSparse(dirs, name) {
+			indexEntry.SkipWorktree = true
+			continue
+		}
+
+		blob, err := localRepo.BlobObject(entry.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = writeBlob(tree, object.NewFile(name, entry.Mode, blob))
+		if err != nil {
+			return err
+		}
+
+		info, err := tree.Filesystem.Lstat(name)
+		if err != nil {
+			return err
+		}
+		indexEntry.Size = uint32(info.Size())
+		indexEntry.ModifiedAt = info.ModTime()
+	}
+
+	return localRepo.Storer.SetIndex(idx)
+}
+
+// inSparse takes the directories of a sparse checkout and the path of a file, and reports
+// whether the file is checked out. Files at the root of the repository always are.
+func inSparse(dirs []string, name string) bool {
+	if !strings.Contains(name, "/") {
+		return true
+	}
+
+	for _, dir := range dirs {
+		dir = strings.Trim(path.Clean(dir), "/")
+		if dir == "." || name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBlob takes a worktree and a file from a commit, and writes the file to the worktree
+// with its mode, or as a symlink.
+func writeBlob(tree *git.Worktree, f *object.File) error {
+	contents, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	if f.Mode == filemode.Symlink {
+		target, err := io.ReadAll(contents)
+		if err != nil {
+			return err
+		}
+		return tree.Filesystem.Symlink(string(target), f.Name)
+	}
+
+	mode, err := f.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	err = tree.Filesystem.MkdirAll(path.Dir(f.Name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	out, err := tree.Filesystem.OpenFile(f.Name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, contents)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isSparse takes a repository and reports whether its worktree is a sparse checkout.
+func isSparse(localRepo *git.Repository) (bool, error) {
+	idx, err := localRepo.Storer.Index()
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range idx.Entries {
+		if entry.SkipWorktree {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unskip takes a repository and removes the skip-worktree entries of a sparse checkout from
+// its index, returning them by name.
+func unskip(localRepo *git.Repository) (map[string]*index.Entry, error) {
+	idx, err := localRepo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := make(map[string]*index.Entry)
+	kept := idx.Entries[:0]
+	for _, entry := range idx.Entries {
+		if entry.SkipWorktree {
+			skipped[entry.Name] = entry
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	if len(skipped) == 0 {
+		return skipped, nil
+	}
+
+	idx.Entries = kept
+	return skipped, localRepo.Storer.SetIndex(idx)
+}
+
+// reskip takes a repository and the entries removed by unskip, and puts back those that
+// haven't since been staged.
+func reskip(localRepo *git.Repository, skipped map[string]*index.Entry) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+
+	idx, err := localRepo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	staged := make(map[string]bool, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		staged[entry.Name] = true
+	}
+
+	for name, entry := range skipped {
+		if !staged[name] {
+			idx.Entries = append(idx.Entries, entry)
+		}
+	}
+
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].Name < idx.Entries[j].Name
+	})
+	idx.Version = 3
+	return localRepo.Storer.SetIndex(idx)
+}
+
+// createBranch takes a repository, a branch and a commit, and creates the branch at the
+// commit and checks it out without touching the worktree.
+func createBranch(localRepo *git.Repository, branch plumbing.ReferenceName, hash plumbing.Hash) error {
+	_, err := localRepo.Reference(branch, false)
+	if err == nil {
+		return fmt.Errorf("a branch named %q already exists", branch)
+	}
+
+	err = localRepo.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+	if err != nil {
+		return err
+	}
+
+	return localRepo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch))
+}
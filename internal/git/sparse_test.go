@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v35/github"
+)
+
+// TestSparseClone checks a shallow, sparse clone only checks out the given directories
+// and the root of the repository, and that committing in it leaves the other files, and
+// submodules, be.
+func TestSparseClone(t *testing.T) {
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+	os.MkdirAll(filepath.Join(origin, "namespaces"), 0o755)
+	os.MkdirAll(filepath.Join(origin, "modules/vpc"), 0o755)
+	commitFiles(t, origin, tree, map[string]string{"README.md": "old"})
+
+	// A submodule is only a gitlink in the index, pointing at a commit of another repository
+	submodule := plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")
+	idx, _ := repo.Storer.Index()
+	idx.Entries = append(idx.Entries, &index.Entry{Name: "shared", Hash: submodule, Mode: filemode.Submodule})
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, origin, tree, map[string]string{
+		"README.md":           "readme",
+		"namespaces/main.tf":  "namespace",
+		"modules/vpc/main.tf": "vpc",
+		"modules/vpc/outputs": "outputs",
+		"namespacesuffix.tf":  "root",
+	})
+
+	dir := t.TempDir()
+	remote := &github.Repository{
+		CloneURL:      github.String("file://" + origin),
+		DefaultBranch: github.String("master"),
+	}
+	localRepo, err := cloneTo(context.Background(), dir, remote, CloneOptions{
+		Depth:        1,
+		SingleBranch: true,
+		Sparse:       []string{"namespaces/"},
+	})
+	if err != nil {
+		t.Fatalf("cloneTo() error = %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"README.md":           true,
+		"namespacesuffix.tf":  true,
+		"namespaces/main.tf":  true,
+		"modules/vpc/main.tf": false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s checked out = %v, want %v", name, got, want)
+		}
+	}
+
+	ref, _ := localRepo.Head()
+	commits, _ := localRepo.Log(&git.LogOptions{From: ref.Hash()})
+	count := 0
+	commits.ForEach(func(*object.Commit) error { count++; return nil })
+	if count != 1 {
+		t.Errorf("cloned %d commits, want 1", count)
+	}
+
+	cfg, _ := localRepo.Config()
+	cfg.User.Name = "test"
+	cfg.User.Email = "test@example.com"
+	localRepo.SetConfig(cfg)
+
+	localTree, _ := localRepo.Worktree()
+	if _, err := Checkout(nil, "campaign", ref, localTree, remote, localRepo); err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "modules/vpc/main.tf")); err == nil {
+		t.Error("Checkout() filled in the sparse checkout")
+	}
+
+	if _, err := Commit(localRepo, localTree, "nothing"); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Commit() of an unchanged checkout error = %v, want ErrNoChanges", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "namespaces/main.tf"), []byte("changed"), 0o644)
+	ioutil.WriteFile(filepath.Join(dir, "namespaces/new.tf"), []byte("new"), 0o644)
+	os.Remove(filepath.Join(dir, "README.md"))
+
+	commit, err := Commit(localRepo, localTree, "campaign")
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	want := map[string]string{
+		"namespacesuffix.tf":  "root",
+		"namespaces/main.tf":  "changed",
+		"namespaces/new.tf":   "new",
+		"modules/vpc/main.tf": "vpc",
+		"modules/vpc/outputs": "outputs",
+	}
+	files, _ := commit.Files()
+	files.ForEach(func(f *object.File) error {
+		content, _ := f.Contents()
+		if content != want[f.Name] {
+			t.Errorf("committed %s = %q, want %q", f.Name, content, want[f.Name])
+		}
+		delete(want, f.Name)
+		return nil
+	})
+	for name := range want {
+		t.Errorf("%s missing from the commit", name)
+	}
+
+	committed, _ := commit.Tree()
+	entry, err := committed.FindEntry("shared")
+	if err != nil || entry.Mode != filemode.Submodule || entry.Hash != submodule {
+		t.Errorf("committed submodule = %+v, %v; want it kept at %s", entry, err, submodule)
+	}
+
+	if _, err := Commit(localRepo, localTree, "again"); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Commit() after committing error = %v, want ErrNoChanges", err)
+	}
+}
@@ -0,0 +1,34 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// Close takes a GitHub client, the repository and a pull request along with a comment
+// explaining why. It comments on the pull request, closes it and deletes its branch.
+func Close(client *github.Client, repo *github.Repository, pull *github.PullRequest, comment string) error {
+	ctx := context.Background()
+	owner, name, number := repo.GetOwner().GetLogin(), repo.GetName(), pull.GetNumber()
+
+	if comment != "" {
+		_, _, err := client.Issues.CreateComment(ctx, owner, name, number, &github.IssueComment{Body: github.String(comment)})
+		if err != nil {
+			return fmt.Errorf("error commenting on pull request: %w", err)
+		}
+	}
+
+	_, _, err := client.PullRequests.Edit(ctx, owner, name, number, &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		return fmt.Errorf("error closing pull request: %w", err)
+	}
+
+	_, err = client.Git.DeleteRef(ctx, owner, name, "heads/"+pull.GetHead().GetRef())
+	if err != nil {
+		return fmt.Errorf("error deleting branch: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// Options are the labels, people and milestone applied to a pull request once it
+// has been created. Draft is applied on creation.
+type Options struct {
+	Labels        []string
+	Reviewers     []string
+	TeamReviewers []string
+	Assignees     []string
+	Milestone     string
+	Draft         bool
+}
+
+// Override takes the key=value options given for a repository in the repository list
+// file and returns a copy of the options with them applied. Keys match the run flags,
+// i.e. label, reviewer, team-reviewer, assignee, milestone and draft; list values are
+// comma separated and replace the values from the flags.
+func (o Options) Override(overrides map[string]string) (Options, error) {
+	for key, value := range overrides {
+		switch key {
+		case "label":
+			o.Labels = splitList(value)
+		case "reviewer":
+			o.Reviewers = splitList(value)
+		case "team-reviewer":
+			o.TeamReviewers = splitList(value)
+		case "assignee":
+			o.Assignees = splitList(value)
+		case "milestone":
+			o.Milestone = value
+		case "draft":
+			draft, err := strconv.ParseBool(value)
+			if err != nil {
+				return o, fmt.Errorf("invalid draft value %q: %w", value, err)
+			}
+			o.Draft = draft
+		default:
+			return o, fmt.Errorf("unknown pull request option %q", key)
+		}
+	}
+
+	return o, nil
+}
+
+// Apply takes a GitHub client, the repository and a newly created pull request. It adds
+// the labels, assignees and milestone via the issues API and then requests reviews.
+func Apply(client *github.Client, repo *github.Repository, pull *github.PullRequest, opts Options) error {
+	ctx := context.Background()
+	owner, name, number := repo.GetOwner().GetLogin(), repo.GetName(), pull.GetNumber()
+
+	if len(opts.Labels) > 0 {
+		_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, name, number, opts.Labels)
+		if err != nil {
+			return fmt.Errorf("error adding labels: %w", err)
+		}
+	}
+
+	if len(opts.Assignees) > 0 {
+		_, _, err := client.Issues.AddAssignees(ctx, owner, name, number, opts.Assignees)
+		if err != nil {
+			return fmt.Errorf("error adding assignees: %w", err)
+		}
+	}
+
+	if opts.Milestone != "" {
+		milestone, err := milestoneNumber(client, repo, opts.Milestone)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = client.Issues.Edit(ctx, owner, name, number, &github.IssueRequest{Milestone: &milestone})
+		if err != nil {
+			return fmt.Errorf("error setting milestone: %w", err)
+		}
+	}
+
+	if len(opts.Reviewers) > 0 || len(opts.TeamReviewers) > 0 {
+		_, _, err := client.PullRequests.RequestReviewers(ctx, owner, name, number, github.ReviewersRequest{
+			Reviewers:     opts.Reviewers,
+			TeamReviewers: opts.TeamReviewers,
+		})
+		if err != nil {
+			return fmt.Errorf("error requesting reviewers: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// milestoneNumber takes a milestone given as either its number or its title and
+// returns its number, looking the title up amongst the repository's open milestones.
+func milestoneNumber(client *github.Client, repo *github.Repository, milestone string) (int, error) {
+	if number, err := strconv.Atoi(milestone); err == nil {
+		return number, nil
+	}
+
+	opt := &github.MilestoneListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), opt)
+		if err != nil {
+			return 0, fmt.Errorf("error listing milestones: %w", err)
+		}
+
+		for _, m := range milestones {
+			if m.GetTitle() == milestone {
+				return m.GetNumber(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return 0, fmt.Errorf("milestone %q not found in %s", milestone, repo.GetName())
+}
+
+// AddReviewers takes users and teams to request reviews from, i.e. from CODEOWNERS, and
+// the login of the pull request author. It returns a copy of the options with them added
+// to the reviewers, skipping duplicates and the author, who can't review their own work.
+func (o Options) AddReviewers(users, teams []string, author string) Options {
+	o.Reviewers = appendUnique(o.Reviewers, users, author)
+	o.TeamReviewers = appendUnique(o.TeamReviewers, teams, "")
+	return o
+}
+
+func appendUnique(list, add []string, skip string) []string {
+	seen := map[string]bool{strings.ToLower(skip): true}
+	out := make([]string, 0, len(list)+len(add))
+	for _, item := range append(append([]string{}, list...), add...) {
+		if seen[strings.ToLower(item)] {
+			continue
+		}
+		seen[strings.ToLower(item)] = true
+		out = append(out, item)
+	}
+	return out
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
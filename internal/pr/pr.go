@@ -0,0 +1,74 @@
+package pr
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v35/github"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/render"
+)
+
+// Data is what the pull request title and body templates are rendered with, i.e.
+// {{.Repo.Name}}, {{.Command}}, {{.CampaignID}} or {{join .Files ", "}}.
+type Data struct {
+	Repo       *github.Repository
+	Command    string
+	CampaignID string
+	Message    string
+	Files      []string
+	Diffstat   string
+}
+
+// NewData takes the remote repository, the command that was run, the campaign ID,
+// the commit message and the commit holding the changes. It returns the data to
+// render pull request templates with.
+func NewData(repo *github.Repository, command, campaignID, message string, commit *object.Commit) (*Data, error) {
+	stats, err := commit.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		files = append(files, stat.Name)
+	}
+
+	return &Data{
+		Repo:       repo,
+		Command:    command,
+		CampaignID: campaignID,
+		Message:    message,
+		Files:      files,
+		Diffstat:   strings.TrimRight(stats.String(), "\n"),
+	}, nil
+}
+
+// Parse takes a title and body template and checks both parse, without rendering them.
+func Parse(title, body string) error {
+	err := render.Parse("pr-title", title)
+	if err != nil {
+		return err
+	}
+	return render.Parse("pr-body", body)
+}
+
+// Render takes a title and body template and renders both with the data. An empty
+// title template falls back to the commit message, as pull requests always had.
+func Render(title, body string, data *Data) (string, string, error) {
+	renderedTitle := data.Message
+	if title != "" {
+		var err error
+		renderedTitle, err = render.Render("pr-title", title, data)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	renderedBody, err := render.Render("pr-body", body, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(renderedTitle), renderedBody, nil
+}
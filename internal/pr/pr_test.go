@@ -0,0 +1,178 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+)
+
+// TestRender checks pull request templates are rendered with the repository and
+// change details, and that the title falls back to the commit message.
+func TestRender(t *testing.T) {
+	data := &Data{
+		Repo:       &github.Repository{Name: github.String("cloud-platform-terraform-rds")},
+		Command:    "terraform 0.13upgrade",
+		CampaignID: "update-tf-action",
+		Message:    "Upgrade {{ terraform }}",
+		Files:      []string{"main.tf", "versions.tf"},
+		Diffstat:   " main.tf | 2 +-",
+	}
+
+	tests := []struct {
+		name      string
+		title     string
+		body      string
+		wantTitle string
+		wantBody  string
+		wantErr   bool
+	}{
+		{
+			name:      "default title is the commit message",
+			wantTitle: "Upgrade {{ terraform }}",
+		},
+		{
+			name:      "templated title and body",
+			title:     "{{.Repo.Name}}: {{.CampaignID}}\n",
+			body:      "Ran `{{.Command}}` changing {{join .Files \", \"}}",
+			wantTitle: "cloud-platform-terraform-rds: update-tf-action",
+			wantBody:  "Ran `terraform 0.13upgrade` changing main.tf, versions.tf",
+		},
+		{
+			name:    "unknown variable",
+			body:    "{{.Nope}}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, body, err := Render(tt.title, tt.body, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if title != tt.wantTitle || body != tt.wantBody {
+				t.Errorf("Render() = %q, %q; want %q, %q", title, body, tt.wantTitle, tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestParse checks malformed title and body templates are caught without any data.
+func TestParse(t *testing.T) {
+	if err := Parse("{{.Repo.Name}}", "Ran `{{.Command}}`"); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+	if err := Parse("{{.Repo.Name", ""); err == nil {
+		t.Error("Parse() of an unclosed title succeeded; want error")
+	}
+	if err := Parse("", "{{join .Files}"); err == nil {
+		t.Error("Parse() of a malformed body succeeded; want error")
+	}
+}
+
+// TestOverride checks per-repository overrides replace the options from the flags.
+func TestOverride(t *testing.T) {
+	flags := Options{Labels: []string{"terraform"}, Reviewers: []string{"alice"}}
+
+	got, err := flags.Override(map[string]string{"label": "rds, terraform", "draft": "true", "milestone": "Q4"})
+	if err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+	want := Options{Labels: []string{"rds", "terraform"}, Reviewers: []string{"alice"}, Milestone: "Q4", Draft: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Override() = %+v, want %+v", got, want)
+	}
+
+	if _, err := flags.Override(map[string]string{"colour": "blue"}); err == nil {
+		t.Error("Override() with an unknown key succeeded; want error")
+	}
+}
+
+// TestClose checks a stale pull request is commented on before it's closed and its branch
+// deleted, and that a failing comment stops it being closed.
+func TestClose(t *testing.T) {
+	repo := &github.Repository{
+		Name:  github.String("cloud-platform-terraform-rds"),
+		Owner: &github.User{Login: github.String("ministryofjustice")},
+	}
+	pull := &github.PullRequest{
+		Number: github.Int(7),
+		Head:   &github.PullRequestBranch{Ref: github.String("update-tf-action")},
+	}
+
+	var calls []string
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				calls = append(calls, "comment "+comment.GetBody())
+				_, _ = w.Write([]byte(`{}`))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var edit github.PullRequest
+				_ = json.NewDecoder(r.Body).Decode(&edit)
+				calls = append(calls, "state "+edit.GetState())
+				_, _ = w.Write([]byte(`{}`))
+			}),
+		),
+		// The mock's own pattern doesn't match refs containing a slash, as branch refs do.
+		mock.WithRequestMatchHandler(
+			mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/git/refs/heads/{branch}", Method: "DELETE"},
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, "delete "+r.URL.Path)
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		),
+	))
+
+	err := Close(client, repo, pull, "No longer needed.")
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{
+		"comment No longer needed.",
+		"state closed",
+		"delete /repos/ministryofjustice/cloud-platform-terraform-rds/git/refs/heads/update-tf-action",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Close() made calls %q, want %q", calls, want)
+	}
+
+	calls = nil
+	failing := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusForbidden, "locked")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, "state")
+				_, _ = w.Write([]byte(`{}`))
+			}),
+		),
+	))
+
+	err = Close(failing, repo, pull, "No longer needed.")
+	if err == nil || !strings.Contains(err.Error(), "error commenting on pull request") {
+		t.Errorf("Close() error = %v, want the comment error", err)
+	}
+	if len(calls) != 0 {
+		t.Error("Close() closed the pull request after failing to comment")
+	}
+}
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/builtin"
+)
+
+// Extractor pulls values out of a file's contents.
+type Extractor interface {
+	Extract(name string, data []byte) ([]string, error)
+}
+
+// ParseExtractor takes an extractor given as kind:expression and returns it. The kinds are:
+//
+//	hcl   an attribute path as given to hcl-set, i.e. hcl:terraform.required_version
+//	yaml  a path as given to yaml-set, i.e. yaml:jobs.*.steps[name=Setup Terraform].with.terraform_version
+//	json  a path like yaml's, optionally starting $., i.e. json:$.dependencies.react
+//	regex a regular expression, returning its first capture group if it has one, i.e.
+//	      regex:required_version\s*=\s*"([^"]+)"
+func ParseExtractor(spec string) (Extractor, error) {
+	i := strings.Index(spec, ":")
+	if i < 1 || i == len(spec)-1 {
+		return nil, fmt.Errorf("invalid extractor %q, want kind:expression i.e. hcl:terraform.required_version", spec)
+	}
+	kind, expr := spec[:i], spec[i+1:]
+
+	switch kind {
+	case "hcl":
+		return hclExtractor(expr), nil
+	case "yaml":
+		return yamlExtractor(expr), nil
+	case "json":
+		// JSON is YAML, so the same paths work once the root is dropped
+		expr = strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+		return yamlExtractor(expr), nil
+	case "regex":
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex extractor: %w", err)
+		}
+		return regexExtractor{re}, nil
+	}
+	return nil, fmt.Errorf("unknown extractor kind %q, want hcl, yaml, json or regex", kind)
+}
+
+type hclExtractor string
+
+func (e hclExtractor) Extract(name string, data []byte) ([]string, error) {
+	return builtin.HCLValues(data, name, string(e))
+}
+
+type yamlExtractor string
+
+func (e yamlExtractor) Extract(name string, data []byte) ([]string, error) {
+	return builtin.YAMLValues(data, string(e))
+}
+
+type regexExtractor struct {
+	re *regexp.Regexp
+}
+
+func (e regexExtractor) Extract(name string, data []byte) ([]string, error) {
+	var values []string
+	for _, match := range e.re.FindAllSubmatch(data, -1) {
+		if len(match) > 1 {
+			values = append(values, string(match[1]))
+		} else {
+			values = append(values, string(match[0]))
+		}
+	}
+	return values, nil
+}
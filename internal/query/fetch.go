@@ -0,0 +1,125 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/go-github/v35/github"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/git"
+)
+
+// File is a file read from a repository.
+type File struct {
+	Path string
+	Data []byte
+}
+
+// Match reports whether a file's path, relative to the repository root, or its name
+// matches one of the globs.
+func Match(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch takes a GitHub client, a repository and globs. It returns every file on the
+// repository's default branch that matches, read through the contents API so nothing is
+// cloned. Only when the repository is too big for GitHub to list in one go is it cloned
+// and the files read from disk instead.
+func Fetch(ctx context.Context, client *github.Client, repo *github.Repository, globs []string) ([]File, error) {
+	owner, name, branch := repo.GetOwner().GetLogin(), repo.GetName(), repo.GetDefaultBranch()
+
+	tree, _, err := client.Git.GetTree(ctx, owner, name, branch, true)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+	if tree.GetTruncated() {
+		return fetchClone(ctx, client, repo, globs)
+	}
+
+	var files []File
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" || !Match(globs, entry.GetPath()) {
+			continue
+		}
+
+		data, err := fetchFile(ctx, client, owner, name, branch, entry.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", entry.GetPath(), err)
+		}
+		files = append(files, File{Path: entry.GetPath(), Data: data})
+	}
+	return files, nil
+}
+
+// fetchFile reads a single file through the contents API, downloading files too large
+// for it to return inline.
+func fetchFile(ctx context.Context, client *github.Client, owner, name, branch, filePath string) ([]byte, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: branch}
+	file, _, _, err := client.Repositories.GetContents(ctx, owner, name, filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.GetEncoding() != "none" {
+		content, err := file.GetContent()
+		return []byte(content), err
+	}
+
+	body, _, err := client.Repositories.DownloadContents(ctx, owner, name, filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// fetchClone clones the repository and reads the matching files from disk, removing the
+// clone afterwards.
+func fetchClone(ctx context.Context, client *github.Client, repo *github.Repository, globs []string) ([]File, error) {
+	repoDir, _, err := git.Clone(ctx, repo, client, git.CloneOptions{Depth: 1, SingleBranch: true})
+	if repoDir != "" {
+		defer os.RemoveAll(repoDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error cloning repository: %w", err)
+	}
+
+	var files []File
+	err = filepath.Walk(repoDir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(repoDir, p)
+		if err != nil || !Match(globs, filepath.ToSlash(rel)) {
+			return err
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,43 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+)
+
+// TestFetch checks matching files are read through the contents API without cloning.
+func TestFetch(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte("terraform {}\n"))
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			github.Tree{Entries: []*github.TreeEntry{
+				{Path: github.String("modules"), Type: github.String("tree")},
+				{Path: github.String("versions.tf"), Type: github.String("blob")},
+				{Path: github.String("README.md"), Type: github.String("blob")},
+			}},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			github.RepositoryContent{Encoding: github.String("base64"), Content: github.String(content)},
+		),
+	))
+
+	repo := &github.Repository{
+		Name:          github.String("cloud-platform-terraform-irsa"),
+		Owner:         &github.User{Login: github.String("ministryofjustice")},
+		DefaultBranch: github.String("main"),
+	}
+	files, err := Fetch(context.Background(), client, repo, []string{"*.tf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Path != "versions.tf" || string(files[0].Data) != "terraform {}\n" {
+		t.Errorf("Fetch() = %+v", files)
+	}
+}
@@ -0,0 +1,127 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Repo is what a query found in a single repository.
+type Repo struct {
+	Name string `json:"name"`
+	// Values are the values found in each file, keyed by path.
+	Values map[string][]string `json:"values,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// Add records the values found in a file.
+func (r *Repo) Add(path string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	if r.Values == nil {
+		r.Values = make(map[string][]string)
+	}
+	r.Values[path] = append(r.Values[path], values...)
+}
+
+// Counts returns how many times each value was found across the repository's files.
+func (r *Repo) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, values := range r.Values {
+		for _, value := range values {
+			counts[value]++
+		}
+	}
+	return counts
+}
+
+// Report collects what a query found in every repository.
+type Report struct {
+	Repos []*Repo `json:"repositories"`
+}
+
+// Errors returns how many repositories couldn't be queried.
+func (r *Report) Errors() int {
+	n := 0
+	for _, repo := range r.Repos {
+		if repo.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes a pivot table to w, with a row per repository and a column per distinct
+// value. Each cell is how many times the repository has the value, and the last row
+// totals how many repositories have it.
+func (r *Report) Print(w io.Writer) error {
+	totals := make(map[string]int)
+	for _, repo := range r.Repos {
+		for value := range repo.Counts() {
+			totals[value]++
+		}
+	}
+	values := make([]string, 0, len(totals))
+	for value := range totals {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := []string{"REPOSITORY"}
+	for _, value := range values {
+		header = append(header, heading(value))
+	}
+	header = append(header, "ERROR")
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, repo := range r.Repos {
+		counts := repo.Counts()
+		row := []string{repo.Name}
+		for _, value := range values {
+			cell := ""
+			if counts[value] > 0 {
+				cell = strconv.Itoa(counts[value])
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(tw, strings.Join(append(row, cell(repo.Error)), "\t"))
+	}
+
+	row := []string{"REPOSITORIES"}
+	for _, value := range values {
+		row = append(row, strconv.Itoa(totals[value]))
+	}
+	fmt.Fprintln(tw, strings.Join(append(row, ""), "\t"))
+
+	return tw.Flush()
+}
+
+// WriteJSON writes every value found, by repository and file, to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// heading makes a value usable as a column heading, quoting it if it's empty so it's
+// visible, or if it would break the table.
+func heading(value string) string {
+	if value == "" {
+		return `""`
+	}
+	return cell(value)
+}
+
+// cell quotes text containing tabs or line breaks, which would otherwise split it across
+// columns or rows of the table.
+func cell(text string) string {
+	if strings.ContainsAny(text, "\t\r\n") {
+		return strconv.Quote(text)
+	}
+	return text
+}
@@ -0,0 +1,140 @@
+package query
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestExtractors checks each kind of extractor pulls values out of a file.
+func TestExtractors(t *testing.T) {
+	tests := []struct {
+		spec    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			spec: "hcl:terraform.required_version",
+			data: "terraform {\n  required_version = \">= 1.2.5\"\n}\n",
+			want: []string{">= 1.2.5"},
+		},
+		{
+			spec: "yaml:jobs.*.steps[name=Setup Terraform].with.terraform_version",
+			data: "jobs:\n  test:\n    steps:\n      - name: Setup Terraform\n        with:\n          terraform_version: 1.2.5\n",
+			want: []string{"1.2.5"},
+		},
+		{
+			spec: "json:$.engines.node",
+			data: `{"name": "app", "engines": {"node": ">=18"}}`,
+			want: []string{">=18"},
+		},
+		{
+			spec: `regex:required_version\s*=\s*"([^"]+)"`,
+			data: "required_version = \">= 0.14\"\nrequired_version = \"1.0.0\"\n",
+			want: []string{">= 0.14", "1.0.0"},
+		},
+		{spec: "regex:([", wantErr: true},
+		{spec: "toml:a.b", wantErr: true},
+		{spec: "hcl", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			extractor, err := ParseExtractor(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExtractor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := extractor.Extract("file", []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPrint checks the pivot table has a column per distinct value and a row per repository.
+func TestPrint(t *testing.T) {
+	a := &Repo{Name: "cloud-platform-terraform-s3-bucket"}
+	a.Add("versions.tf", []string{">= 1.2.5"})
+	a.Add("examples/versions.tf", []string{">= 0.14"})
+	b := &Repo{Name: "cloud-platform-terraform-irsa"}
+	b.Add("versions.tf", []string{">= 1.2.5"})
+	c := &Repo{Name: "cloud-platform-terraform-rds", Error: "error listing files: 404"}
+
+	r := &Report{Repos: []*Repo{a, b, c}}
+
+	var buf bytes.Buffer
+	if err := r.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"REPOSITORY                          >= 0.14  >= 1.2.5  ERROR",
+		"cloud-platform-terraform-s3-bucket  1        1         ",
+		"cloud-platform-terraform-irsa                1         ",
+		"cloud-platform-terraform-rds                           error listing files: 404",
+		"REPOSITORIES                        1        2         ",
+	}
+	if got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Print() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.Errors() != 1 {
+		t.Errorf("Errors() = %d, want 1", r.Errors())
+	}
+}
+
+// TestPrintMultiline checks values spanning lines, such as HCL objects, are quoted so they
+// keep to their column.
+func TestPrintMultiline(t *testing.T) {
+	extractor, err := ParseExtractor("hcl:locals.tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := extractor.Extract("main.tf", []byte("locals {\n  tags = {\n\tteam = \"webops\"\n  }\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &Repo{Name: "cloud-platform-terraform-rds"}
+	repo.Add("main.tf", values)
+	r := &Report{Repos: []*Repo{repo}}
+
+	var buf bytes.Buffer
+	if err := r.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(got) != 3 {
+		t.Fatalf("Print() wrote %d lines, want 3:\n%s", len(got), buf.String())
+	}
+	heading := strconv.Quote(values[0])
+	if !strings.HasPrefix(got[0], "REPOSITORY                    "+heading+"  ERROR") {
+		t.Errorf("Print() heading = %q, want the value quoted", got[0])
+	}
+}
+
+// TestMatch checks globs match either the path or the name of a file.
+func TestMatch(t *testing.T) {
+	globs := []string{"*.tf", ".github/workflows/*.yml"}
+	for path, want := range map[string]bool{
+		"versions.tf":                true,
+		"modules/vpc/main.tf":        true,
+		".github/workflows/unit.yml": true,
+		"unit.yml":                   false,
+		"README.md":                  false,
+	} {
+		if got := Match(globs, path); got != want {
+			t.Errorf("Match(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
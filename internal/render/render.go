@@ -0,0 +1,83 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// funcs are the helpers available to every template the cli renders.
+var funcs = template.FuncMap{
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"shellquote": ShellQuote,
+}
+
+// RepoData is what per-repository templates, such as the command, are rendered with,
+// i.e. {{.Repo.Name}} or {{.Repo.DefaultBranch}}.
+type RepoData struct {
+	Repo *github.Repository
+}
+
+// ShellQuote takes any number of strings and returns them single quoted for /bin/sh and
+// separated by spaces, so a value containing spaces or quotes stays a single argument,
+// i.e. {{shellquote .Repo.Description}}.
+func ShellQuote(args ...interface{}) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		s := fmt.Sprint(indirect(arg))
+		quoted = append(quoted, "'"+strings.ReplaceAll(s, "'", `'"'"'`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+// indirect dereferences the pointers go-github uses for every field, so they quote as
+// their value rather than an address.
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return ""
+	}
+	return rv.Interface()
+}
+
+// Parse takes a template name and its text, and returns the error parsing the text as a Go
+// template, if any, so a malformed template can be caught before any repository is touched.
+func Parse(name, text string) error {
+	_, err := parse(name, text)
+	return err
+}
+
+// parse takes a template name and its text and parses it with the cli's helpers.
+func parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// Render takes a template name, its text and the data to execute it with. It parses the text
+// as a Go template and returns the rendered string, or an error naming the template.
+func Render(name, text string, data interface{}) (string, error) {
+	tmpl, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
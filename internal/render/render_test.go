@@ -0,0 +1,41 @@
+package render
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// TestRenderCommand checks repository fields expand in a command and that shellquote
+// keeps awkward values as a single argument to the shell.
+func TestRenderCommand(t *testing.T) {
+	data := RepoData{Repo: &github.Repository{
+		Name:        github.String("cloud-platform-terraform-rds"),
+		Description: github.String("RDS module's \"terraform\" $HOME"),
+	}}
+
+	got, err := Render("command", `sed -i 's/old/{{.Repo.Name}}/' README.md`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `sed -i 's/old/cloud-platform-terraform-rds/' README.md`; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	got, err = Render("command", `printf %s {{shellquote .Repo.Description}}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("/bin/sh", "-c", got).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != data.Repo.GetDescription() {
+		t.Errorf("shell saw %q, want %q", out, data.Repo.GetDescription())
+	}
+
+	if _, err := Render("command", "{{.Repo.Nope}}", data); err == nil {
+		t.Error("Render() of an unknown field succeeded; want error")
+	}
+}
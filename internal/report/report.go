@@ -0,0 +1,98 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ministryofjustice/cloud-platform-git-xargs/internal/execute"
+	"github.com/ministryofjustice/cloud-platform-git-xargs/pkg/transform"
+)
+
+// Statuses a repository can finish a run with.
+const (
+	Success     = "success"
+	Partial     = "partial"
+	NoChanges   = "no changes"
+	ClosedStale = "closed stale"
+	Failed      = "failed"
+	Cancelled   = "cancelled"
+	Skipped     = "skipped"
+)
+
+// Repo is the outcome of processing a single repository.
+type Repo struct {
+	Name        string             `json:"name"`
+	Status      string             `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	PullRequest string             `json:"pull_request,omitempty"`
+	CloneDir    string             `json:"clone_dir,omitempty"`
+	LogFile     string             `json:"log_file,omitempty"`
+	Output      []execute.Result   `json:"output,omitempty"`
+	Transforms  []transform.Result `json:"transforms,omitempty"`
+}
+
+// Report collects the outcome of every repository in a run.
+type Report struct {
+	mu    sync.Mutex
+	Repos []*Repo `json:"repositories"`
+}
+
+// Add records a repository's outcome. It's safe for concurrent use.
+func (r *Report) Add(repo *Repo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Repos = append(r.Repos, repo)
+}
+
+// Count returns how many repositories finished with the given status.
+func (r *Report) Count(status string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, repo := range r.Repos {
+		if repo.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes a table of every repository's status, pull request and error to w.
+func (r *Report) Print(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tSTATUS\tPULL REQUEST\tLOG\tERROR")
+	for _, repo := range r.Repos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", repo.Name, repo.Status, repo.PullRequest, repo.LogFile, firstLine(repo.Error))
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the full report, including captured command output, to a file.
+func (r *Report) WriteJSON(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
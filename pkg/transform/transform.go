@@ -0,0 +1,172 @@
+// Package transform lets in-process transformations be run on each repository by
+// cloud-platform-git-xargs run --transform, instead of shelling out to a command. The
+// built-in transformations implement Transformer, and a build of the CLI can register
+// its own alongside them:
+//
+//	func main() {
+//		transform.Register("rename-team", transform.Func(renameTeam))
+//		cmd.Execute()
+//	}
+package transform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/google/go-github/v35/github"
+)
+
+// Args are the key=value arguments given to a transformation via --transform-args.
+type Args map[string]string
+
+// ParseArgs takes key=value pairs and returns them as Args.
+func ParseArgs(pairs []string) (Args, error) {
+	args := make(Args)
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid transform argument %q, want key=value", pair)
+		}
+		args[pair[:i]] = pair[i+1:]
+	}
+	return args, nil
+}
+
+// Get returns the argument for key, or def if it wasn't given.
+func (a Args) Get(key, def string) string {
+	if value, ok := a[key]; ok {
+		return value
+	}
+	return def
+}
+
+// Required returns the argument for key, or an error if it wasn't given.
+func (a Args) Required(key string) (string, error) {
+	value, ok := a[key]
+	if !ok || value == "" {
+		return "", fmt.Errorf("missing required argument %q", key)
+	}
+	return value, nil
+}
+
+// List returns the comma separated argument for key, or def if it wasn't given.
+func (a Args) List(key string, def ...string) []string {
+	value, ok := a[key]
+	if !ok {
+		return def
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Bool returns the argument for key parsed as a boolean, or def if it wasn't given.
+func (a Args) Bool(key string, def bool) (bool, error) {
+	value, ok := a[key]
+	if !ok {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s argument %q: %w", key, value, err)
+	}
+	return b, nil
+}
+
+// Input is what a transformation is given for each repository.
+type Input struct {
+	// Dir is the directory to transform, the root of the clone or the step's dir.
+	Dir string
+	// Repo is the GitHub repository the clone is of.
+	Repo *github.Repository
+	// Tree is the clone's worktree, on the campaign's branch. Transformations only need
+	// to edit files; they're committed afterwards.
+	Tree *git.Worktree
+	// Client can be used to look things up on GitHub, i.e. the latest release.
+	Client *github.Client
+	// Args are the transformation's arguments, from --transform-args or a campaign step.
+	Args Args
+	// DryRun is set by --skip-commit. Transformations may report what they would change
+	// rather than changing it.
+	DryRun bool
+}
+
+// Result describes what a transformation changed.
+type Result struct {
+	Name string `json:"name"`
+	// Files are the paths changed, relative to Dir.
+	Files []string `json:"files,omitempty"`
+	// Summary is a human readable account of the changes, used in the pull request.
+	Summary string `json:"summary,omitempty"`
+	// Matches counts what the transformation found in each file, i.e. replace's matches.
+	Matches map[string]int `json:"matches,omitempty"`
+}
+
+// Transformer edits a clone of a repository in place.
+type Transformer interface {
+	Transform(ctx context.Context, in *Input) (*Result, error)
+}
+
+// Func lets an ordinary function be used as a Transformer.
+type Func func(ctx context.Context, in *Input) (*Result, error)
+
+// Transform calls f(ctx, in).
+func (f Func) Transform(ctx context.Context, in *Input) (*Result, error) {
+	return f(ctx, in)
+}
+
+var (
+	mu           sync.RWMutex
+	transformers = make(map[string]Transformer)
+)
+
+// Register makes a transformation available to --transform and campaign steps under name.
+// It panics if name is already registered or t is nil, as that's a mistake in the build.
+func Register(name string, t Transformer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if t == nil {
+		panic("transform: Register transformer is nil")
+	}
+	if _, dup := transformers[name]; dup {
+		panic("transform: Register called twice for " + name)
+	}
+	transformers[name] = t
+}
+
+// Lookup returns the transformation registered as name.
+func Lookup(name string) (Transformer, error) {
+	mu.RLock()
+	t, ok := transformers[name]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown transformation %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Names returns the names of every registered transformation, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"context"
+	"testing"
+)
+
+// TestParseArgs checks key=value pairs are split on the first equals sign.
+func TestParseArgs(t *testing.T) {
+	args, err := ParseArgs([]string{"path=terraform.required_version", "value=>= 1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args["path"] != "terraform.required_version" || args["value"] != ">= 1.2" {
+		t.Errorf("ParseArgs() = %v", args)
+	}
+
+	if _, err := ParseArgs([]string{"novalue"}); err == nil {
+		t.Error("ParseArgs() expected an error for a pair without =")
+	}
+}
+
+// TestRegister checks registered transformations can be looked up by name, and that
+// registering a name twice panics.
+func TestRegister(t *testing.T) {
+	noop := Func(func(ctx context.Context, in *Input) (*Result, error) {
+		return &Result{Name: "noop"}, nil
+	})
+	Register("noop", noop)
+
+	got, err := Lookup("noop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := got.Transform(context.Background(), &Input{})
+	if err != nil || result.Name != "noop" {
+		t.Errorf("Transform() = %v, %v", result, err)
+	}
+
+	if _, err := Lookup("missing"); err == nil {
+		t.Error("Lookup() expected an error for an unregistered name")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() expected a panic registering noop twice")
+		}
+	}()
+	Register("noop", noop)
+}